@@ -0,0 +1,444 @@
+package xld
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//ApplyAction is the kind of change an ApplyChange makes to a ci
+type ApplyAction string
+
+//The changes an apply plan can hold
+const (
+	ApplyCreate ApplyAction = "create"
+	ApplyUpdate ApplyAction = "update"
+	ApplyDelete ApplyAction = "delete"
+)
+
+//ApplyOptions controls how PlanApply compares the desired cis with the repository
+type ApplyOptions struct {
+	// Prune deletes the cis under Root that are not part of the desired set
+	Prune bool
+	Root  string
+}
+
+//PropertyDiff is a property that differs between the repository and the desired ci
+// Old is nil for properties that are not set in the repository
+type PropertyDiff struct {
+	Name string
+	Old  interface{}
+	New  interface{}
+}
+
+//ApplyChange is a single change to the repository
+// for updates Ci holds the complete ci that is saved, Diffs the properties that change
+type ApplyChange struct {
+	Action ApplyAction
+	Ci     Ci
+	Diffs  []PropertyDiff
+}
+
+//ApplyPlan holds the changes needed to bring the repository in line with a desired set of cis
+// creates and updates are in dependency order, deletes come last
+type ApplyPlan struct {
+	Changes []ApplyChange
+}
+
+//LoadCis reads a json or yaml list of cis in the flat format xldeploy uses ({"id": .., "type": .., <properties>})
+// the format is detected from the content
+func LoadCis(r io.Reader) ([]Ci, error) {
+	var c []Ci
+
+	err := decodeJSONOrYAML(r, &c)
+
+	return c, err
+}
+
+//PlanApply compares the desired cis with the repository property by property and returns the changes needed
+// only the properties that are set on a desired ci are compared, password properties that are set in
+// the repository are never reported as changed because xldeploy does not return them in clear text
+func (r RepositoryServiceOp) PlanApply(desired []Ci, o ApplyOptions) (ApplyPlan, error) {
+	return r.PlanApplyContext(context.Background(), desired, o)
+}
+
+//PlanApplyContext is PlanApply with a context that is attached to every request it makes
+func (r RepositoryServiceOp) PlanApplyContext(ctx context.Context, desired []Ci, o ApplyOptions) (ApplyPlan, error) {
+	var p ApplyPlan
+
+	ordered, err := applyOrder(desired)
+	if err != nil {
+		return p, err
+	}
+
+	for _, c := range ordered {
+		current, err := r.GetCiContext(ctx, c.ID)
+		if IsNotFound(err) {
+			p.Changes = append(p.Changes, ApplyChange{Action: ApplyCreate, Ci: c})
+			continue
+		}
+		if err != nil {
+			return p, err
+		}
+
+		if current.Type != c.Type {
+			return p, fmt.Errorf("ci %s is a %s in the repository, it can not be changed into a %s", c.ID, current.Type, c.Type)
+		}
+
+		meta, err := r.client.Meta.GetTypeContext(ctx, c.Type)
+		if err != nil {
+			return p, err
+		}
+
+		diffs, err := diffCiProperties(meta, current, c)
+		if err != nil {
+			return p, err
+		}
+
+		if len(diffs) == 0 {
+			continue
+		}
+
+		// the properties that are not managed are kept as they are in the repository
+		update := current
+		update.Properties = make(map[string]interface{}, len(current.Properties))
+		for k, v := range current.Properties {
+			update.Properties[k] = v
+		}
+		for _, d := range diffs {
+			update.Properties[d.Name] = c.Properties[d.Name]
+		}
+
+		p.Changes = append(p.Changes, ApplyChange{Action: ApplyUpdate, Ci: update, Diffs: diffs})
+	}
+
+	if o.Prune && o.Root != "" {
+		deletes, err := r.pruneCandidates(ctx, desired, o.Root)
+		if err != nil {
+			return p, err
+		}
+		p.Changes = append(p.Changes, deletes...)
+	}
+
+	return p, nil
+}
+
+//Apply executes the changes of a plan in order
+// it stops at the first change that fails
+func (r RepositoryServiceOp) Apply(p ApplyPlan) error {
+	return r.ApplyContext(context.Background(), p)
+}
+
+//ApplyContext is Apply with a context that is attached to every request it makes
+func (r RepositoryServiceOp) ApplyContext(ctx context.Context, p ApplyPlan) error {
+	for _, c := range p.Changes {
+		var err error
+
+		switch c.Action {
+		case ApplyCreate:
+			_, err = r.CreateCiContext(ctx, c.Ci.ID, c.Ci.Type, c.Ci.Properties)
+		case ApplyUpdate:
+			_, err = r.SaveCiContext(ctx, c.Ci)
+		case ApplyDelete:
+			err = r.DeleteCiContext(ctx, c.Ci.ID)
+		default:
+			err = fmt.Errorf("unknown apply action %s", c.Action)
+		}
+
+		if err != nil {
+			return &ApplyError{Change: c, Err: err}
+		}
+	}
+
+	return nil
+}
+
+//ApplyError is returned by Apply when a change of the plan fails
+// Err is the error the change failed with, the Is functions look through to it
+type ApplyError struct {
+	Change ApplyChange
+	Err    error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Change.Action, e.Change.Ci.ID, e.Err)
+}
+
+//Cause returns the error the change failed with
+func (e *ApplyError) Cause() error {
+	return e.Err
+}
+
+//Unwrap returns the error the change failed with, for use with errors.Is and errors.As
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+//Empty returns true when the repository is already in the desired state
+func (p ApplyPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+//String renders the plan in a human readable form, the property changes of updates are indented
+func (p ApplyPlan) String() string {
+	if p.Empty() {
+		return "no changes\n"
+	}
+
+	var b bytes.Buffer
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ApplyCreate:
+			fmt.Fprintf(&b, "+ create %s (%s)\n", c.Ci.ID, c.Ci.Type)
+		case ApplyUpdate:
+			fmt.Fprintf(&b, "~ update %s\n", c.Ci.ID)
+			for _, d := range c.Diffs {
+				fmt.Fprintf(&b, "    %s: %v -> %v\n", d.Name, d.Old, d.New)
+			}
+		case ApplyDelete:
+			fmt.Fprintf(&b, "- delete %s (%s)\n", c.Ci.ID, c.Ci.Type)
+		}
+	}
+
+	return b.String()
+}
+
+//private functions
+
+//diffCiProperties compares the properties set on desired with their value in current
+func diffCiProperties(meta MetaData, current, desired Ci) ([]PropertyDiff, error) {
+	var diffs []PropertyDiff
+
+	kinds := make(map[string]Property, len(meta.Properties))
+	for _, p := range meta.Properties {
+		kinds[p.Name] = p
+	}
+
+	var names []string
+	for k := range desired.Properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		p, ok := kinds[n]
+		if !ok {
+			return nil, &PropertiesError{CiID: desired.ID, Type: desired.Type, Errors: []PropertyError{{Name: n, Reason: "unknown property for type " + desired.Type}}}
+		}
+
+		want, err := canonicalProperty(p.Kind, desired.Properties[n])
+		if err != nil {
+			return nil, &PropertiesError{CiID: desired.ID, Type: desired.Type, Errors: []PropertyError{{Name: n, Kind: p.Kind, Reason: err.Error()}}}
+		}
+
+		if p.Password && current.Properties[n] != nil {
+			continue
+		}
+
+		// a value the repository holds that does not fit the kind is reported as changed
+		have, err := canonicalProperty(p.Kind, current.Properties[n])
+		if err != nil {
+			have = current.Properties[n]
+		}
+
+		if !reflect.DeepEqual(have, want) {
+			diffs = append(diffs, PropertyDiff{Name: n, Old: have, New: want})
+		}
+	}
+
+	return diffs, nil
+}
+
+//canonicalProperty converts v like it is sent to xldeploy so values from different sources can be compared
+// empty values become nil and sets are sorted
+func canonicalProperty(kind string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	cv, err := convertProperty(kind, v)
+	if err != nil {
+		return nil, err
+	}
+
+	if isEmptyProperty(cv) {
+		return nil, nil
+	}
+
+	if m, ok := cv.(map[string]string); ok && len(m) == 0 {
+		return nil, nil
+	}
+
+	if kind == KindSetOfString || kind == KindSetOfCi {
+		l := append([]string(nil), cv.([]string)...)
+		sort.Strings(l)
+		return l, nil
+	}
+
+	return cv, nil
+}
+
+//applyOrder sorts the cis so that parents and referenced cis in the set come before the cis that need them
+func applyOrder(c []Ci) ([]Ci, error) {
+	byID := make(map[string]Ci, len(c))
+	for _, ci := range c {
+		if _, ok := byID[ci.ID]; ok {
+			return nil, fmt.Errorf("ci %s is listed more than once", ci.ID)
+		}
+		byID[ci.ID] = ci
+	}
+
+	// deps holds for every ci the cis in the set it depends on
+	deps := make(map[string]map[string]bool, len(c))
+	for _, ci := range c {
+		deps[ci.ID] = make(map[string]bool)
+
+		for parent := parentID(ci.ID); parent != ""; parent = parentID(parent) {
+			if _, ok := byID[parent]; ok {
+				deps[ci.ID][parent] = true
+			}
+		}
+
+		for _, ref := range ciReferences(ci.Properties) {
+			if _, ok := byID[ref]; ok && ref != ci.ID {
+				deps[ci.ID][ref] = true
+			}
+		}
+	}
+
+	var ordered []Ci
+	done := make(map[string]bool, len(c))
+
+	for len(ordered) < len(c) {
+		var ready []string
+		for id, d := range deps {
+			if done[id] {
+				continue
+			}
+
+			ok := true
+			for dep := range d {
+				if !done[dep] {
+					ok = false
+					break
+				}
+			}
+
+			if ok {
+				ready = append(ready, id)
+			}
+		}
+
+		if len(ready) == 0 {
+			var left []string
+			for id := range deps {
+				if !done[id] {
+					left = append(left, id)
+				}
+			}
+			sort.Strings(left)
+			return nil, fmt.Errorf("cis reference each other in a cycle: %s", strings.Join(left, ", "))
+		}
+
+		sort.Strings(ready)
+		for _, id := range ready {
+			done[id] = true
+			ordered = append(ordered, byID[id])
+		}
+	}
+
+	return ordered, nil
+}
+
+//ciReferences returns the ids of the cis referenced by the properties
+// without metadata every string that looks like a repository id is taken as reference
+func ciReferences(p map[string]interface{}) []string {
+	var refs []string
+
+	for _, v := range p {
+		if id, ok := toCiRef(v); ok {
+			if _, err := validateID(id); err == nil {
+				refs = append(refs, id)
+			}
+			continue
+		}
+
+		if l, ok := toCiRefs(v); ok {
+			for _, id := range l {
+				if _, err := validateID(id); err == nil {
+					refs = append(refs, id)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+//pruneCandidates returns the deletes for the cis under root that are not desired
+// the ancestors of desired cis are kept and only the topmost ci of an unwanted subtree is deleted
+func (r RepositoryServiceOp) pruneCandidates(ctx context.Context, desired []Ci, root string) ([]ApplyChange, error) {
+	var deletes []ApplyChange
+
+	keep := make(map[string]bool)
+	for _, c := range desired {
+		for id := c.ID; id != ""; id = parentID(id) {
+			keep[id] = true
+		}
+	}
+
+	deleted := make(map[string]bool)
+	var entries []CiListEntry
+
+	it := r.QueryAllContext(ctx, QueryOptions{Ancestor: root})
+	for it.Next() {
+		entries = append(entries, it.Entry())
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	// parents sort before their children
+	sort.Sort(byEntryID(entries))
+
+	for _, e := range entries {
+		if keep[e.ID] || e.ID == root {
+			continue
+		}
+
+		covered := false
+		for parent := parentID(e.ID); parent != ""; parent = parentID(parent) {
+			if deleted[parent] {
+				covered = true
+				break
+			}
+		}
+
+		deleted[e.ID] = true
+		if !covered {
+			deletes = append(deletes, ApplyChange{Action: ApplyDelete, Ci: Ci{ID: e.ID, Type: e.Type}})
+		}
+	}
+
+	return deletes, nil
+}
+
+//parentID returns the id of the parent of ci id, or an empty string for a root
+func parentID(id string) string {
+	i := strings.LastIndex(id, "/")
+	if i < 0 {
+		return ""
+	}
+
+	return id[:i]
+}
+
+type byEntryID []CiListEntry
+
+func (b byEntryID) Len() int           { return len(b) }
+func (b byEntryID) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byEntryID) Less(i, j int) bool { return b[i].ID < b[j].ID }
@@ -0,0 +1,189 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanApply(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+	mux.HandleFunc("/deployit/metadata/type/udm.Environment", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestEnvironmentMetaResponse)
+	})
+
+	// everything that changes the repository is recorded
+	var requests []string
+	mux.HandleFunc("/deployit/repository/ci/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/deployit/repository/ci/")
+
+		if r.Method != "GET" {
+			requests = append(requests, r.Method+" "+id)
+			fmt.Fprint(w, `{}`)
+			return
+		}
+
+		switch id {
+		case "Infrastructure/web1":
+			fmt.Fprint(w, `{"id": "Infrastructure/web1", "type": "overthere.SshHost", "$token": "abc",
+				"os": "UNIX", "address": "web1", "port": "22", "tags": ["b", "a"], "password": "{b64}xyz"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/deployit/repository/exists/", func(w http.ResponseWriter, r *http.Request) {
+		exists := strings.HasSuffix(r.URL.Path, "/Infrastructure/web1")
+		fmt.Fprintf(w, `{"boolean": %t}`, exists)
+	})
+	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ancestor") != "Infrastructure" {
+			t.Errorf("Expected a query under Infrastructure but got %v", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[
+			{"ref": "Infrastructure/old", "type": "core.Directory"},
+			{"ref": "Infrastructure/old/db1", "type": "overthere.SshHost"},
+			{"ref": "Infrastructure/web1", "type": "overthere.SshHost"},
+			{"ref": "Infrastructure/web2", "type": "overthere.SshHost"}]`)
+	})
+
+	desired, err := LoadCis(strings.NewReader(mockTestDesiredCis))
+	if err != nil {
+		t.Fatalf("LoadCis returned error: %v", err)
+	}
+
+	p, err := client.Repository.PlanApply(desired, ApplyOptions{Prune: true, Root: "Infrastructure"})
+	if err != nil {
+		t.Fatalf("PlanApply returned error: %v", err)
+	}
+
+	expected := `~ update Infrastructure/web1
+    port: 22 -> 2222
++ create Infrastructure/web2 (overthere.SshHost)
++ create Environments/dev (udm.Environment)
+- delete Infrastructure/old (core.Directory)
+`
+
+	if p.String() != expected {
+		t.Errorf("PlanApply returned\n%v\nexpected\n%v", p, expected)
+	}
+
+	if err := client.Repository.Apply(p); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	expectedRequests := []string{
+		"PUT Infrastructure/web1",
+		"POST Infrastructure/web2",
+		"POST Environments/dev",
+		"DELETE Infrastructure/old",
+	}
+
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Apply sent %v, expected %v", requests, expectedRequests)
+	}
+}
+
+func TestApplyValidationError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+	mux.HandleFunc("/deployit/repository/exists/Infrastructure/testHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"boolean": false}`)
+	})
+	mux.HandleFunc("/deployit/repository/ci/Infrastructure/testHost", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, mockTestInvalidHostResponse)
+	})
+
+	p := ApplyPlan{Changes: []ApplyChange{{
+		Action: ApplyCreate,
+		Ci:     Ci{ID: "Infrastructure/testHost", Type: "overthere.SshHost", Properties: map[string]interface{}{"os": "UNIX"}},
+	}}}
+
+	err := client.Repository.Apply(p)
+	if !IsValidationError(err) {
+		t.Fatalf("Expected a validation error but got %v", err)
+	}
+
+	e, ok := err.(*ApplyError)
+	if !ok {
+		t.Fatalf("Expected an ApplyError but got %T", err)
+	}
+
+	if _, ok := e.Cause().(*ValidationError); !ok || e.Change.Ci.ID != "Infrastructure/testHost" {
+		t.Errorf("ApplyError holds %v for %v, expected the ValidationError of Infrastructure/testHost", e.Err, e.Change.Ci.ID)
+	}
+}
+
+func TestApplyOrderCycle(t *testing.T) {
+	_, err := applyOrder([]Ci{
+		{ID: "Environments/a", Type: "udm.Environment", Properties: map[string]interface{}{"members": []string{"Environments/b"}}},
+		{ID: "Environments/b", Type: "udm.Environment", Properties: map[string]interface{}{"members": []string{"Environments/a"}}},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected a cycle error but got %v", err)
+	}
+}
+
+func TestLoadCisYAML(t *testing.T) {
+	j, err := LoadCis(strings.NewReader(mockTestDesiredCis))
+	if err != nil {
+		t.Fatalf("LoadCis returned error for json: %v", err)
+	}
+
+	y, err := LoadCis(strings.NewReader(mockTestDesiredCisYAML))
+	if err != nil {
+		t.Fatalf("LoadCis returned error for yaml: %v", err)
+	}
+
+	if !reflect.DeepEqual(j, y) {
+		t.Errorf("yaml cis %+v differ from json cis %+v", y, j)
+	}
+}
+
+var mockTestDesiredCisYAML = `
+- id: Environments/dev
+  type: udm.Environment
+  members: [Infrastructure/web1, Infrastructure/web2]
+- id: Infrastructure/web1
+  type: overthere.SshHost
+  os: UNIX
+  address: web1
+  port: 2222
+  tags:
+    - a
+    - b
+  password: secret
+- id: Infrastructure/web2
+  type: overthere.SshHost
+  os: UNIX
+  address: web2
+`
+
+var mockTestDesiredCis = `[
+  {"id": "Environments/dev", "type": "udm.Environment", "members": ["Infrastructure/web1", "Infrastructure/web2"]},
+  {"id": "Infrastructure/web1", "type": "overthere.SshHost", "os": "UNIX", "address": "web1", "port": 2222,
+   "tags": ["a", "b"], "password": "secret"},
+  {"id": "Infrastructure/web2", "type": "overthere.SshHost", "os": "UNIX", "address": "web2"}
+]`
+
+var mockTestEnvironmentMetaResponse = `{
+  "type": "udm.Environment",
+  "root": "Environments",
+  "properties": [
+    {"name": "members", "kind": "SET_OF_CI", "referencedType": "udm.Container"},
+    {"name": "dictionaries", "kind": "LIST_OF_CI", "referencedType": "udm.Dictionary"}
+  ]
+}`
@@ -0,0 +1,149 @@
+package xld
+
+import (
+	"context"
+	"encoding/json"
+)
+
+//CreateCis creates multiple CIs in one request
+// when xldeploy rejects any of them a *BatchValidationError is returned
+func (r RepositoryServiceOp) CreateCis(c []Ci) ([]Ci, error) {
+	return r.CreateCisContext(context.Background(), c)
+}
+
+//CreateCisContext is CreateCis with a context that is attached to every request it makes
+func (r RepositoryServiceOp) CreateCisContext(ctx context.Context, c []Ci) ([]Ci, error) {
+	return r.batch(ctx, "POST", c)
+}
+
+//UpdateCis updates multiple existing CIs in one request
+// when xldeploy rejects any of them a *BatchValidationError is returned,
+// when one of them was changed since it was read a *ConflictError
+func (r RepositoryServiceOp) UpdateCis(c []Ci) ([]Ci, error) {
+	return r.UpdateCisContext(context.Background(), c)
+}
+
+//UpdateCisContext is UpdateCis with a context that is attached to every request it makes
+func (r RepositoryServiceOp) UpdateCisContext(ctx context.Context, c []Ci) ([]Ci, error) {
+	return r.batch(ctx, "PUT", c)
+}
+
+//GetCis retrieves multiple CIs in one request
+func (r RepositoryServiceOp) GetCis(n ...string) ([]Ci, error) {
+	return r.GetCisContext(context.Background(), n...)
+}
+
+//GetCisContext is GetCis with a context that is attached to every request it makes
+func (r RepositoryServiceOp) GetCisContext(ctx context.Context, n ...string) ([]Ci, error) {
+	var cis []Ci
+
+	for _, id := range n {
+		if _, err := validateID(id); err != nil {
+			return cis, err
+		}
+	}
+
+	url := repositoryBasePath + "/cis/read"
+
+	req, err := r.client.NewRequestContext(ctx, url, "POST", n)
+	if err != nil {
+		return cis, err
+	}
+
+	_, err = r.client.Do(req, &cis)
+
+	return cis, err
+}
+
+//private functions
+
+func (r RepositoryServiceOp) batch(ctx context.Context, verb string, c []Ci) ([]Ci, error) {
+	var rc []Ci
+
+	body := make([]map[string]interface{}, len(c))
+
+	// the metadata is fetched once per type instead of once per ci
+	metaData := make(map[string]map[string]string)
+
+	for i, ci := range c {
+		if _, err := validateID(ci.ID); err != nil {
+			return rc, err
+		}
+
+		if _, ok := metaData[ci.Type]; !ok {
+			m, err := r.client.Meta.GetPropertiesContext(ctx, ci.Type)
+			if err != nil {
+				return rc, err
+			}
+			metaData[ci.Type] = m
+		}
+
+		b, err := translateCi(ci.ID, ci.Type, ci.Properties, metaData[ci.Type])
+		if err != nil {
+			return rc, err
+		}
+
+		body[i] = b
+		if verb == "PUT" && ci.Token != "" {
+			body[i]["$token"] = ci.Token
+		}
+	}
+
+	url := repositoryBasePath + "/cis"
+
+	req, err := r.client.NewRequestContext(ctx, url, verb, body)
+	if err != nil {
+		return rc, err
+	}
+
+	_, err = r.client.Do(req, &rc)
+	if IsConflict(err) {
+		ids := make([]string, len(c))
+		for i, ci := range c {
+			ids[i] = ci.ID
+		}
+		return rc, &ConflictError{IDs: ids}
+	}
+	if err != nil {
+		return rc, batchValidationError(err, c)
+	}
+
+	return rc, nil
+}
+
+//batchValidationError maps the validation messages xldeploy sends back for a batch onto the cis that were sent
+func batchValidationError(err error, c []Ci) error {
+	e, ok := err.(*ErrorResponse)
+	if !ok {
+		return err
+	}
+
+	var rc []Ci
+	if json.Unmarshal([]byte(e.Message), &rc) != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(c))
+	for i, ci := range c {
+		index[ci.ID] = i
+	}
+
+	be := &BatchValidationError{Errors: make([]*ValidationError, len(c))}
+	found := false
+
+	for _, ci := range rc {
+		i, ok := index[ci.ID]
+		if !ok || len(ci.ValidationMessages) == 0 {
+			continue
+		}
+
+		be.Errors[i] = &ValidationError{CiID: ci.ID, Messages: ci.ValidationMessages}
+		found = true
+	}
+
+	if !found {
+		return err
+	}
+
+	return be
+}
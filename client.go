@@ -2,10 +2,14 @@ package xld
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 )
 
 const (
@@ -24,6 +28,12 @@ type Config struct {
 	Port     string
 	Context  string
 	Scheme   string
+	// MetaDataTTL is how long type descriptors are cached, zero means DefaultMetaDataTTL
+	// and a negative value disables the cache
+	MetaDataTTL time.Duration
+	// ValidateBeforeSave makes CreateCi and SaveCi check the ci against its type metadata
+	// with ValidateCi before it is sent to xldeploy
+	ValidateBeforeSave bool
 }
 
 //Client holds all the settings needed to communicate with xl-release
@@ -40,9 +50,16 @@ type Client struct {
 	// Client Config
 	Config *Config
 
+	// mu guards the credentials in Config, they change when the user changes its own password
+	mu sync.RWMutex
+
 	Repository RepositoryService
 	Meta       MetaDataService
 	Security   SecurityService
+	Deployment DeploymentService
+	Task       TaskService
+	Package    PackageService
+	Server     ServerService
 }
 
 //NewClient returns a new functional client struct
@@ -57,8 +74,12 @@ func NewClient(config *Config) *Client {
 	c := &Client{client: http.DefaultClient, BaseURL: &baseURL, UserAgent: userAgent, Config: config}
 
 	c.Repository = &RepositoryServiceOp{client: c}
-	c.Meta = &MetaDataServiceOp{client: c}
+	c.Meta = &MetaDataServiceOp{client: c, cache: newMetaCache(config.MetaDataTTL)}
 	c.Security = &SecurityServiceOp{client: c}
+	c.Deployment = &DeploymentServiceOp{client: c}
+	c.Task = &TaskServiceOp{client: c}
+	c.Package = &PackageServiceOp{client: c}
+	c.Server = &ServerServiceOp{client: c}
 
 	return c
 }
@@ -75,12 +96,12 @@ func New(config *Config) *Client {
 // BaseURL of the Client. Relative URLS should always be specified without a preceding slash. If specified, the
 // value pointed to by body is JSON encoded and included in as the request body.
 func (c *Client) NewRequest(urlStr string, method string, body interface{}) (*http.Request, error) {
-	rel, err := url.Parse(urlStr)
-	if err != nil {
-		return nil, err
-	}
+	return c.NewRequestContext(context.Background(), urlStr, method, body)
+}
 
-	u := c.BaseURL.ResolveReference(rel)
+//NewRequestContext creates an API request like NewRequest does and attaches ctx to it. Canceling ctx, or
+// passing its deadline, aborts the request when it is sent with Do.
+func (c *Client) NewRequestContext(ctx context.Context, urlStr string, method string, body interface{}) (*http.Request, error) {
 	buf := new(bytes.Buffer)
 
 	if body != nil {
@@ -89,21 +110,46 @@ func (c *Client) NewRequest(urlStr string, method string, body interface{}) (*ht
 			return nil, err
 		}
 	}
-	req, err := http.NewRequest(method, u.String(), buf)
+
+	return c.NewStreamRequestContext(ctx, urlStr, method, mediaType, buf)
+}
+
+//NewStreamRequestContext creates an API request that sends body as it is read instead of json encoding it
+// contentType is sent as the Content-Type of the body, responses are still expected to be json
+func (c *Client) NewStreamRequestContext(ctx context.Context, urlStr string, method string, contentType string, body io.Reader) (*http.Request, error) {
+	rel, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, err
 	}
 
-	req.SetBasicAuth(c.Config.User, c.Config.Password)
-	req.Header.Add("Content-Type", mediaType)
+	u := c.BaseURL.ResolveReference(rel)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(c.credentials())
+	req.Header.Add("Content-Type", contentType)
 	req.Header.Add("Accept", mediaType)
 	req.Header.Add("User-Agent", c.UserAgent)
-	return req, nil
+	return req.WithContext(ctx), nil
+}
+
+//NewMultipartRequestContext creates a multipart/form-data API request with a single file field
+// the content of the file is streamed from r while the request is sent, so large files are never held in memory.
+// nothing is read from r until the request is sent
+func (c *Client) NewMultipartRequestContext(ctx context.Context, urlStr string, field string, filename string, r io.Reader) (*http.Request, error) {
+	pr, pw := io.Pipe()
+	b := &multipartBody{pr: pr, pw: pw, mw: multipart.NewWriter(pw), field: field, filename: filename, r: r}
+
+	return c.NewStreamRequestContext(ctx, urlStr, "POST", b.mw.FormDataContentType(), b)
 }
 
 // Do sends an API request and returns the API response. The API response is JSON decoded and stored in the value
 // pointed to by v, or returned as an error if an API error has occurred. If v implements the io.Writer interface,
 // the raw response will be written to v, without attempting to decode it.
+// Any response with a status code outside of the 2xx range results in an *ErrorResponse.
 func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 
 	resp, err := c.client.Do(req)
@@ -119,7 +165,23 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 		}
 	}()
 
-	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, newErrorResponse(resp)
+	}
+
+	if v == nil {
+		return resp, err
+	}
+
+	if w, ok := v.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return resp, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && err != io.EOF {
 		// body, err := ioutil.ReadAll(resp.Body)
 		// fmt.Println(string(body))
 		return nil, err
@@ -128,31 +190,61 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 	return resp, err
 }
 
-//VerifyConnection verifies that we have a valid connection to xld
-func (c *Client) VerifyConnection() bool {
+//private functions
+
+//multipartBody is the body of a multipart request, it encodes the file through a pipe
+// the copy only starts on the first Read, so a request that is never sent leaves no goroutine behind
+type multipartBody struct {
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+	mw       *multipart.Writer
+	field    string
+	filename string
+	r        io.Reader
+	once     sync.Once
+}
 
-	rel, err := url.Parse("deployit/server/info")
-	if err != nil {
-		return false
-	}
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(func() { go b.copy() })
 
-	u := c.BaseURL.ResolveReference(rel)
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return false
+	return b.pr.Read(p)
+}
+
+//Close is called by the transport when the request is done or fails, which ends the copy
+func (b *multipartBody) Close() error {
+	return b.pr.Close()
+}
+
+func (b *multipartBody) copy() {
+	part, err := b.mw.CreateFormFile(b.field, b.filename)
+	if err == nil {
+		_, err = io.Copy(part, b.r)
+	}
+	if err == nil {
+		err = b.mw.Close()
 	}
+	b.pw.CloseWithError(err)
+}
 
-	req.SetBasicAuth(c.Config.User, c.Config.Password)
-	req.Header.Add("Content-Type", mediaType)
-	req.Header.Add("Accept", "Application/xml")
-	req.Header.Add("User-Agent", c.UserAgent)
+//credentials returns the user and password requests are sent with
+func (c *Client) credentials() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	resp, err := c.client.Do(req)
+	return c.Config.User, c.Config.Password
+}
 
-	if resp.StatusCode == 200 && err == nil {
-		fmt.Println("Goxld: Succesfully conntected to XL-Deploy")
-		return true
-	}
+//setPassword changes the password requests are sent with, requests that are already built keep the old one
+func (c *Client) setPassword(p string) {
+	c.mu.Lock()
+	c.Config.Password = p
+	c.mu.Unlock()
+}
+
+//VerifyConnection verifies that we have a valid connection to xld
+// use Server.Info to find out why the connection fails
+func (c *Client) VerifyConnection() bool {
+	_, err := c.Server.Info()
 
-	return false
+	return err == nil
 }
@@ -1,6 +1,8 @@
 package xld
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -43,7 +45,7 @@ func teardown() {
 
 func testClientServices(t *testing.T, c *Client) {
 	services := []string{
-		"Repository", "Meta",
+		"Repository", "Meta", "Security", "Deployment", "Task", "Package", "Server",
 	}
 
 	cp := reflect.ValueOf(c)
@@ -114,6 +116,57 @@ func TestDo_httpError(t *testing.T) {
 	}
 }
 
+func TestDo_errorResponse(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/ci/Environments/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Repository entity Environments/missing not found")
+	})
+
+	_, err := client.Repository.GetCi("Environments/missing")
+
+	e, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("Expected an *ErrorResponse but got %v", err)
+	}
+
+	if e.StatusCode != http.StatusNotFound || e.Method != "GET" {
+		t.Errorf("Expected GET 404 but got %v %v", e.Method, e.StatusCode)
+	}
+
+	if e.URL != server.URL+"/deployit/repository/ci/Environments/missing" {
+		t.Errorf("Expected the request url in the error but got %v", e.URL)
+	}
+
+	if e.Message != "Repository entity Environments/missing not found" {
+		t.Errorf("Expected the server message in the error but got %q", e.Message)
+	}
+
+	if !IsNotFound(err) || IsConflict(err) || IsUnauthorized(err) || IsValidationError(err) {
+		t.Errorf("Expected only IsNotFound to match %v", err)
+	}
+}
+
+func TestDo_canceledContext(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/udm.Dictionary", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected the request not to reach the server")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Meta.GetTypeContext(ctx, "udm.Dictionary")
+	if err == nil {
+		t.Error("Expected an error for a canceled context.")
+	}
+}
+
 func testMethod(t *testing.T, r *http.Request, expected string) {
 	if expected != r.Method {
 		t.Errorf("Request method = %v, expected %v", r.Method, expected)
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/wianvos/xld"
+)
+
+//goTypes maps the xldeploy property kinds onto the go type of the generated field
+// booleans and integers are pointers so an unset field can be told apart from false or 0
+var goTypes = map[string]string{
+	xld.KindBoolean:         "*bool",
+	xld.KindInteger:         "*int",
+	xld.KindString:          "string",
+	xld.KindEnum:            "string",
+	xld.KindDate:            "string",
+	xld.KindCi:              "string",
+	xld.KindSetOfString:     "[]string",
+	xld.KindSetOfCi:         "[]string",
+	xld.KindListOfString:    "[]string",
+	xld.KindListOfCi:        "[]string",
+	xld.KindMapStringString: "map[string]string",
+}
+
+type genType struct {
+	Name        string
+	Type        string
+	Description string
+	Fields      []genField
+}
+
+type genField struct {
+	Name     string
+	Property string
+	Kind     string
+	GoType   string
+	Comment  string
+	// IsSet is the condition under which ToCi sends the field, Value the value it sends
+	IsSet string
+	Value string
+}
+
+//generate renders the go source for the non virtual types in meta as package pkg
+// it fails when two types or two properties of a type end up with the same go name
+func generate(pkg string, meta xld.MetaDataList) ([]byte, error) {
+	var types []genType
+
+	names := make(map[string]string)
+
+	for _, m := range meta {
+		if m.Virtual {
+			continue
+		}
+
+		t, err := newGenType(m)
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := names[t.Name]; ok {
+			return nil, fmt.Errorf("types %s and %s both generate %s", other, m.Type, t.Name)
+		}
+		names[t.Name] = m.Type
+
+		types = append(types, t)
+	}
+
+	sort.Sort(byName(types))
+
+	var buf bytes.Buffer
+	err := sourceTemplate.Execute(&buf, struct {
+		Package string
+		Types   []genType
+	}{pkg, types})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func newGenType(m xld.MetaData) (genType, error) {
+	t := genType{Name: goName(m.Type), Type: m.Type, Description: oneLine(m.Description)}
+
+	// ID and Type are taken by the ci itself, the others by the generated methods
+	reserved := map[string]bool{"ID": true, "Type": true, "CiType": true, "ToCi": true, "FromCi": true}
+	used := make(map[string]string)
+
+	for _, p := range m.Properties {
+		kind := p.Kind
+		goType, ok := goTypes[kind]
+		if !ok {
+			kind = ""
+			goType = "interface{}"
+		}
+
+		n := goName(p.Name)
+		if reserved[n] {
+			n = n + "Property"
+		}
+
+		if other, ok := used[n]; ok {
+			return t, fmt.Errorf("properties %s and %s of %s both generate field %s", other, p.Name, m.Type, n)
+		}
+		used[n] = p.Name
+
+		f := genField{Name: n, Property: p.Name, Kind: kind, GoType: goType, Comment: oneLine(p.Description)}
+
+		// only fields that are set are sent, xldeploy keeps its defaults for the others
+		switch {
+		case strings.HasPrefix(goType, "*"):
+			f.IsSet, f.Value = "c."+n+" != nil", "*c."+n
+		case goType == "string":
+			f.IsSet, f.Value = "c."+n+` != ""`, "c."+n
+		case goType == "interface{}":
+			f.IsSet, f.Value = "c."+n+" != nil", "c."+n
+		default:
+			f.IsSet, f.Value = "len(c."+n+") > 0", "c."+n
+		}
+
+		t.Fields = append(t.Fields, f)
+	}
+
+	return t, nil
+}
+
+//goName turns a type or property name like overthere.SshHost into an exported go identifier like OverthereSshHost
+func goName(s string) string {
+	var buf bytes.Buffer
+	upper := true
+
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+
+		if buf.Len() == 0 && unicode.IsDigit(r) {
+			buf.WriteRune('X')
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+type byName []genType
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+var sourceTemplate = template.Must(template.New("source").Parse(`// Code generated by xld-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wianvos/xld"
+)
+{{range .Types}}
+//{{.Name}} is a typed {{.Type}} ci{{if .Description}}
+// {{.Description}}{{end}}
+type {{.Name}} struct {
+	ID string ` + "`json:\"-\"`" + `
+{{- range .Fields}}
+	{{if .Comment}}// {{.Comment}}
+	{{end}}{{.Name}} {{.GoType}} ` + "`json:\"{{.Property}},omitempty\"`" + `
+{{- end}}
+}
+
+//CiType returns the xldeploy type of {{.Name}}
+func ({{.Name}}) CiType() string {
+	return "{{.Type}}"
+}
+
+//ToCi converts {{.Name}} to a generic xld.Ci
+// fields that are not set are left out so xldeploy keeps their current or default value
+func (c {{.Name}}) ToCi() xld.Ci {
+	p := make(map[string]interface{})
+{{- range .Fields}}
+	if {{.IsSet}} {
+		p["{{.Property}}"] = {{.Value}}
+	}
+{{- end}}
+
+	return xld.Ci{ID: c.ID, Type: "{{.Type}}", Properties: p}
+}
+
+//FromCi fills {{.Name}} from a generic xld.Ci
+func (c *{{.Name}}) FromCi(ci xld.Ci) error {
+	*c = {{.Name}}{ID: ci.ID}
+
+	return decodeProperties(ci, map[string]string{
+	{{- range .Fields}}{{if .Kind}}
+		"{{.Property}}": "{{.Kind}}",
+	{{- end}}{{end}}
+	}, c)
+}
+{{end}}
+//decodeProperties converts the properties of ci to the kinds of a generated type and decodes them into v
+// xldeploy sends integers and booleans as strings, they are converted before decoding
+func decodeProperties(ci xld.Ci, kinds map[string]string, v interface{}) error {
+	p := make(map[string]interface{}, len(ci.Properties))
+
+	for n, pv := range ci.Properties {
+		if kind, ok := kinds[n]; ok && pv != nil {
+			cv, err := xld.ConvertProperty(kind, pv)
+			if err != nil {
+				return fmt.Errorf("property %s of %s: %v", n, ci.ID, err)
+			}
+			pv = cv
+		}
+		p[n] = pv
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+`))
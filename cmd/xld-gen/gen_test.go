@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wianvos/xld"
+)
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"overthere.SshHost":  "OverthereSshHost",
+		"udm.Dictionary":     "UdmDictionary",
+		"connectionType":     "ConnectionType",
+		"jee-ear.Deployment": "JeeEarDeployment",
+		"2fa":                "X2fa",
+	}
+
+	for in, expected := range cases {
+		if n := goName(in); n != expected {
+			t.Errorf("goName(%v) returned %v, expected %v", in, n, expected)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var meta xld.MetaDataList
+	if err := json.Unmarshal([]byte(mockTestTypes), &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generate("cis", meta)
+	if err != nil {
+		t.Fatalf("generate returned error: %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "cis.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	s := string(src)
+
+	for _, expected := range []string{
+		"type OverthereSshHost struct",
+		"Port         *int              `json:\"port,omitempty\"`",
+		"Sudo         *bool             `json:\"sudo,omitempty\"`",
+		"Tags         []string          `json:\"tags,omitempty\"`",
+		"TypeProperty string            `json:\"type,omitempty\"`",
+		"EnvVars      map[string]string `json:\"envVars,omitempty\"`",
+		"p[\"envVars\"] = c.EnvVars",
+		"func (c *OverthereSshHost) FromCi(ci xld.Ci) error",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected the generated source to contain %v\n%s", expected, s)
+		}
+	}
+
+	if strings.Contains(s, "UdmBaseContainer") {
+		t.Errorf("Expected virtual types to be skipped\n%s", s)
+	}
+}
+
+func TestGenerateNameCollision(t *testing.T) {
+	cases := map[string]string{
+		`[{"type": "a.b.FooBar"}, {"type": "a.bFoo.Bar"}]`:                                                                     "types a.b.FooBar and a.bFoo.Bar both generate ABFooBar",
+		`[{"type": "a.Host", "properties": [{"name": "foo-bar", "kind": "STRING"}, {"name": "fooBar", "kind": "STRING"}]}]`:    "properties foo-bar and fooBar of a.Host both generate field FooBar",
+		`[{"type": "a.Host", "properties": [{"name": "type", "kind": "STRING"}, {"name": "typeProperty", "kind": "STRING"}]}]`: "properties type and typeProperty of a.Host both generate field TypeProperty",
+	}
+
+	for types, expected := range cases {
+		var meta xld.MetaDataList
+		if err := json.Unmarshal([]byte(types), &meta); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := generate("cis", meta); err == nil || err.Error() != expected {
+			t.Errorf("generate returned %v for %v, expected %v", err, types, expected)
+		}
+	}
+}
+
+// TestGenerateRoundTrip compiles the generated code and runs a ci through ToCi and FromCi
+func TestGenerateRoundTrip(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	var meta xld.MetaDataList
+	if err := json.Unmarshal([]byte(mockTestTypes), &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generate("main", meta)
+	if err != nil {
+		t.Fatalf("generate returned error: %v", err)
+	}
+
+	// the directory is created next to the test so the xld import resolves, go ignores names starting with _
+	dir, err := ioutil.TempDir(".", "_roundtrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"cis.go": string(src), "main.go": mockTestRoundTripProgram}
+	for n, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, n), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command(goTool, "run", filepath.Join(dir, "cis.go"), filepath.Join(dir, "main.go")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("generated code does not run: %v\n%s\n%s", err, out, src)
+	}
+
+	expected := `{"os":"UNIX"}
+{"port":22,"sudo":false}
+{"ID":"Infrastructure/host","Os":"UNIX","Port":2222,"Sudo":true,"Tags":["a"],"TypeProperty":"","EnvVars":{"A":"b"}}
+`
+	if string(out) != expected {
+		t.Errorf("round trip returned\n%s\nexpected\n%s", out, expected)
+	}
+}
+
+var mockTestRoundTripProgram = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wianvos/xld"
+)
+
+func print(v interface{}) {
+	b, _ := json.Marshal(v)
+	fmt.Println(string(b))
+}
+
+func main() {
+	// only the fields that are set end up in the properties
+	print(OverthereSshHost{Os: "UNIX"}.ToCi().Properties)
+
+	port, sudo := 22, false
+	print(OverthereSshHost{Port: &port, Sudo: &sudo}.ToCi().Properties)
+
+	// xldeploy sends integers and booleans as strings
+	var h OverthereSshHost
+	err := h.FromCi(xld.Ci{ID: "Infrastructure/host", Type: "overthere.SshHost", Properties: map[string]interface{}{
+		"os": "UNIX", "port": "2222", "sudo": "true", "tags": []interface{}{"a"}, "envVars": map[string]interface{}{"A": "b"},
+	}})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	print(struct {
+		ID           string
+		Os           string
+		Port         int
+		Sudo         bool
+		Tags         []string
+		TypeProperty string
+		EnvVars      map[string]string
+	}{h.ID, h.Os, *h.Port, *h.Sudo, h.Tags, h.TypeProperty, h.EnvVars})
+
+	if c := h.ToCi(); c.ID != h.ID || len(c.Properties) != 5 {
+		fmt.Println("ToCi lost fields:", c)
+	}
+}
+`
+
+var mockTestTypes = `[
+  {"type": "overthere.SshHost", "description": "A machine reached over ssh", "properties": [
+    {"name": "os", "kind": "ENUM", "description": "Operating system"},
+    {"name": "port", "kind": "INTEGER"},
+    {"name": "sudo", "kind": "BOOLEAN"},
+    {"name": "tags", "kind": "SET_OF_STRING"},
+    {"name": "type", "kind": "STRING"},
+    {"name": "envVars", "kind": "MAP_STRING_STRING"}
+  ]},
+  {"type": "udm.BaseContainer", "virtual": true}
+]`
@@ -0,0 +1,116 @@
+//xld-gen generates typed go structs for xldeploy ci types
+//
+// the type descriptors are read from a running xldeploy server or from a json dump of
+// the deployit/metadata/type endpoint:
+//
+//	xld-gen -host localhost -port 4516 -user admin -password admin -types overthere.SshHost,udm.Dictionary -o cis.go
+//	xld-gen -in types.json -package cis -o cis.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/wianvos/xld"
+)
+
+func main() {
+	var (
+		config xld.Config
+		types  string
+		in     string
+		out    string
+		pkg    string
+	)
+
+	flag.StringVar(&config.Host, "host", "localhost", "xldeploy host")
+	flag.StringVar(&config.Port, "port", "4516", "xldeploy port")
+	flag.StringVar(&config.User, "user", "admin", "xldeploy user")
+	flag.StringVar(&config.Password, "password", "", "xldeploy password")
+	flag.StringVar(&config.Scheme, "scheme", "http", "xldeploy scheme")
+	flag.StringVar(&config.Context, "context", "", "xldeploy context root")
+	flag.StringVar(&types, "types", "", "comma separated list of types to generate, all types when empty")
+	flag.StringVar(&in, "in", "", "json file holding the type descriptors, read instead of contacting xldeploy")
+	flag.StringVar(&out, "o", "", "output file, stdout when empty")
+	flag.StringVar(&pkg, "package", "cis", "package name of the generated code")
+	flag.Parse()
+
+	meta, err := load(&config, in, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xld-gen:", err)
+		os.Exit(1)
+	}
+
+	src, err := generate(pkg, meta)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xld-gen:", err)
+		os.Exit(1)
+	}
+
+	if out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+
+	if err := ioutil.WriteFile(out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "xld-gen:", err)
+		os.Exit(1)
+	}
+}
+
+//load reads the type descriptors from file in, or from xldeploy when in is empty
+// only the types in the comma separated list types are returned, unless it is empty
+func load(config *xld.Config, in, types string) (xld.MetaDataList, error) {
+	var meta xld.MetaDataList
+
+	wanted := make(map[string]bool)
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			wanted[t] = true
+		}
+	}
+
+	switch {
+	case in != "":
+		b, err := ioutil.ReadFile(in)
+		if err != nil {
+			return meta, err
+		}
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return meta, err
+		}
+	case len(wanted) > 0:
+		c := xld.NewClient(config)
+		for t := range wanted {
+			m, err := c.Meta.GetType(t)
+			if err != nil {
+				return meta, err
+			}
+			meta = append(meta, m)
+		}
+		return meta, nil
+	default:
+		l, err := xld.NewClient(config).Meta.ListTypes()
+		if err != nil {
+			return meta, err
+		}
+		meta = l
+	}
+
+	if len(wanted) == 0 {
+		return meta, nil
+	}
+
+	var filtered xld.MetaDataList
+	for _, m := range meta {
+		if wanted[m.Type] {
+			filtered = append(filtered, m)
+		}
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,70 @@
+//xld-security brings the users, roles and permissions of an xldeploy server in line with a json or yaml state file
+//
+// it prints the changes it is about to make and applies them, unless -dry-run is given:
+//
+//	xld-security -host localhost -port 4516 -user admin -password admin -f security.yaml -prune
+//
+// the format of the state file is detected from its content, yaml uses the same keys as json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wianvos/xld"
+)
+
+func main() {
+	var (
+		config xld.Config
+		file   string
+		prune  bool
+		dryRun bool
+	)
+
+	flag.StringVar(&config.Host, "host", "localhost", "xldeploy host")
+	flag.StringVar(&config.Port, "port", "4516", "xldeploy port")
+	flag.StringVar(&config.User, "user", "admin", "xldeploy user")
+	flag.StringVar(&config.Password, "password", "", "xldeploy password")
+	flag.StringVar(&config.Scheme, "scheme", "http", "xldeploy scheme")
+	flag.StringVar(&config.Context, "context", "", "xldeploy context root")
+	flag.StringVar(&file, "f", "security.json", "json or yaml file holding the desired security state")
+	flag.BoolVar(&prune, "prune", false, "remove the roles that are not in the state file")
+	flag.BoolVar(&dryRun, "dry-run", false, "only print the plan")
+	flag.Parse()
+
+	if err := run(&config, file, prune, dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, "xld-security:", err)
+		os.Exit(1)
+	}
+}
+
+func run(config *xld.Config, file string, prune, dryRun bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	desired, err := xld.LoadSecurityState(f)
+	if err != nil {
+		return err
+	}
+
+	r := xld.NewSecurityReconciler(xld.NewClient(config).Security)
+	r.Prune = prune
+
+	p, err := r.Plan(desired)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(p)
+
+	if dryRun || p.Empty() {
+		return nil
+	}
+
+	return r.Apply(p)
+}
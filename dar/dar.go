@@ -0,0 +1,251 @@
+//Package dar builds xldeploy deployment archives (dar files)
+//
+// a dar is a zip holding a deployit-manifest.xml that describes the deployables of an
+// application version, together with the files of the artifacts among them
+package dar
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/wianvos/xld"
+)
+
+//ManifestName is the name of the manifest inside a dar
+const ManifestName = "deployit-manifest.xml"
+
+//Package is an application version that can be written as a dar
+// Properties are set on the udm.DeploymentPackage itself, like orchestrator or satisfiesReleaseNotes
+type Package struct {
+	Application string
+	Version     string
+	Properties  map[string]interface{}
+	Deployables []*Deployable
+
+	// kinds holds the property kinds per type as learned by Validate
+	kinds map[string]map[string]string
+}
+
+//Deployable is a single deployable in a dar
+// Properties use the same model as xld.Ci.Properties, references to other cis can be written
+// as Ref or []Ref, or as plain ids once Validate has learned the property kinds from xldeploy
+type Deployable struct {
+	Type         string
+	Name         string
+	Properties   map[string]interface{}
+	Tags         []string
+	Placeholders []string
+	Artifact     *Artifact
+}
+
+//Artifact is the file or folder of an artifact deployable
+type Artifact struct {
+	// Name is the name of the file or folder in the dar, it defaults to the base name of Path
+	Name string
+	// Path is a local file or folder
+	Path string
+	// Data is the content of an in-memory file, it is used when Path is empty
+	Data []byte
+}
+
+//Ref is a reference to another ci in a property value
+type Ref string
+
+//New returns an empty package for version of application
+func New(application, version string) *Package {
+	return &Package{Application: application, Version: version, Properties: make(map[string]interface{})}
+}
+
+//Add adds a deployable without artifact to the package
+func (p *Package) Add(t, name string, props map[string]interface{}) *Deployable {
+	d := &Deployable{Type: t, Name: name, Properties: props}
+	if d.Properties == nil {
+		d.Properties = make(map[string]interface{})
+	}
+
+	p.Deployables = append(p.Deployables, d)
+
+	return d
+}
+
+//AddFile adds an artifact deployable whose content is the local file or folder at path
+func (p *Package) AddFile(t, name, path string, props map[string]interface{}) *Deployable {
+	d := p.Add(t, name, props)
+	d.Artifact = &Artifact{Path: path}
+
+	return d
+}
+
+//AddData adds an artifact deployable whose content is a single file held in memory
+func (p *Package) AddData(t, name, filename string, data []byte, props map[string]interface{}) *Deployable {
+	d := p.Add(t, name, props)
+	d.Artifact = &Artifact{Name: filename, Data: data}
+
+	return d
+}
+
+//ID returns the id the package gets in the xldeploy repository
+func (p *Package) ID() string {
+	return "Applications/" + p.Application + "/" + p.Version
+}
+
+//Validate checks the deployables against the type metadata of xldeploy
+// every deployable that is invalid gets an entry in the returned *xld.BatchValidationError.
+// the property kinds it learns are used by Write to render the properties
+func (p *Package) Validate(m xld.MetaDataService) error {
+	if p.Application == "" || p.Version == "" {
+		return fmt.Errorf("a package needs an application and a version")
+	}
+
+	be := &xld.BatchValidationError{Errors: make([]*xld.ValidationError, len(p.Deployables))}
+	found := false
+
+	names := make(map[string]bool, len(p.Deployables))
+
+	for i, d := range p.Deployables {
+		id := p.ID() + "/" + d.Name
+		e := &xld.ValidationError{CiID: id}
+		invalid := func(prop, msg string) {
+			e.Messages = append(e.Messages, xld.ValidationMessage{CiID: id, Property: prop, Level: xld.ValidationLevelError, Message: msg})
+		}
+
+		if names[d.Name] {
+			invalid("name", "name is used by more than one deployable")
+		}
+		names[d.Name] = true
+
+		if err := p.validate(m, d, invalid); err != nil {
+			return err
+		}
+
+		if len(e.Messages) > 0 {
+			be.Errors[i] = e
+			found = true
+		}
+	}
+
+	if found {
+		return be
+	}
+
+	return nil
+}
+
+//private functions
+
+func (p *Package) validate(m xld.MetaDataService, d *Deployable, invalid func(prop, msg string)) error {
+	meta, err := m.GetType(d.Type)
+	if xld.IsNotFound(err) {
+		invalid("type", "unknown type "+d.Type)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if meta.Virtual {
+		invalid("type", d.Type+" is virtual")
+	}
+
+	deployable, err := m.IsSubtypeOf(d.Type, "udm.Deployable")
+	if err != nil {
+		return err
+	}
+	if !deployable {
+		invalid("type", d.Type+" is not a udm.Deployable")
+	}
+
+	artifact, err := m.IsSubtypeOf(d.Type, "udm.Artifact")
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case artifact && d.Artifact == nil:
+		invalid("file", d.Type+" is an artifact and needs a file")
+	case !artifact && d.Artifact != nil:
+		invalid("file", d.Type+" is not an artifact and can not have a file")
+	case d.Artifact != nil && d.Artifact.Path != "":
+		if _, err := os.Stat(d.Artifact.Path); err != nil {
+			invalid("file", err.Error())
+		}
+	}
+
+	kinds := make(map[string]string, len(meta.Properties))
+	for _, prop := range meta.Properties {
+		kinds[prop.Name] = prop.Kind
+
+		v, ok := d.Properties[prop.Name]
+		if !ok || v == nil {
+			if prop.Required && prop.Default == nil && !prop.AsContainment {
+				invalid(prop.Name, "property is required")
+			}
+			continue
+		}
+
+		cv, err := xld.ConvertProperty(prop.Kind, plainRefs(v))
+		if err != nil {
+			invalid(prop.Name, err.Error())
+			continue
+		}
+
+		if prop.Kind == xld.KindEnum && len(prop.EnumValues) > 0 && !isPlaceholder(cv.(string)) && !contains(prop.EnumValues, cv.(string)) {
+			invalid(prop.Name, "value "+cv.(string)+" is not one of the allowed values")
+		}
+	}
+
+	for n := range d.Properties {
+		if _, ok := kinds[n]; !ok {
+			invalid(n, "unknown property for type "+d.Type)
+		}
+	}
+
+	if p.kinds == nil {
+		p.kinds = make(map[string]map[string]string)
+	}
+	p.kinds[d.Type] = kinds
+
+	return nil
+}
+
+//file returns the path of the artifact inside the dar
+func (d *Deployable) file() string {
+	if d.Artifact == nil {
+		return ""
+	}
+
+	n := d.Artifact.Name
+	if n == "" {
+		n = filepath.Base(d.Artifact.Path)
+	}
+
+	return path.Join(d.Name, n)
+}
+
+//plainRefs turns Ref values into plain ids so they can be converted like other references
+func plainRefs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Ref:
+		return string(v)
+	case []Ref:
+		l := make([]string, len(v))
+		for i, r := range v {
+			l[i] = string(r)
+		}
+		return l
+	}
+
+	return v
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
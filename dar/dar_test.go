@@ -0,0 +1,181 @@
+package dar
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wianvos/xld"
+)
+
+var (
+	mux    *http.ServeMux
+	server *httptest.Server
+	client *xld.Client
+)
+
+func setup() {
+	mux = http.NewServeMux()
+	server = httptest.NewServer(mux)
+
+	client = xld.NewClient(&xld.Config{User: "admin", Password: "password", Host: "localhost", Port: "4516", Scheme: "http"})
+	client.BaseURL, _ = url.Parse(server.URL)
+
+	mux.HandleFunc("/deployit/metadata/type", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTypes)
+	})
+	client.Meta.Preload()
+}
+
+func teardown() {
+	server.Close()
+}
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "static", "css"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "static", "index.html"), []byte("<h1>{{TITLE}}</h1>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "static", "css", "site.css"), []byte("body {}"), 0644)
+
+	p := New("PetClinic", "1.0")
+	p.Add("cmd.Command", "migrate", map[string]interface{}{
+		"commandLine":  "migrate.sh",
+		"order":        60,
+		"dependencies": []Ref{"Applications/PetClinic/1.0/config"},
+	}).Tags = []string{"db"}
+	p.AddData("file.File", "config", "app.properties", []byte("url={{DB_URL}}"), nil).Placeholders = []string{"DB_URL"}
+	p.AddFile("file.Folder", "static", filepath.Join(dir, "static"), map[string]interface{}{
+		"targetPath": "/var/www",
+	})
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Write did not produce a zip: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, _ := f.Open()
+		b, _ := ioutil.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(b)
+	}
+
+	for _, n := range []string{"config/app.properties", "static/static/index.html", "static/static/css/site.css"} {
+		if _, ok := files[n]; !ok {
+			t.Errorf("Expected %v in the dar but got %v", n, files)
+		}
+	}
+
+	sum := sha1.Sum([]byte("url={{DB_URL}}"))
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<udm.DeploymentPackage version="1.0" application="PetClinic">
+  <deployables>
+    <cmd.Command name="migrate">
+      <commandLine>migrate.sh</commandLine>
+      <dependencies>
+        <ci ref="Applications/PetClinic/1.0/config"></ci>
+      </dependencies>
+      <order>60</order>
+      <tags>
+        <value>db</value>
+      </tags>
+    </cmd.Command>
+    <file.File name="config" file="config/app.properties">
+      <checksum>` + hex.EncodeToString(sum[:]) + `</checksum>
+      <placeholders>
+        <value>DB_URL</value>
+      </placeholders>
+    </file.File>
+    <file.Folder name="static" file="static/static">
+      <targetPath>/var/www</targetPath>
+    </file.Folder>
+  </deployables>
+</udm.DeploymentPackage>
+`
+
+	if files[ManifestName] != expected {
+		t.Errorf("Write produced manifest\n%v\nexpected\n%v", files[ManifestName], expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	p := New("PetClinic", "1.0")
+	p.Add("cmd.Command", "migrate", map[string]interface{}{"commandLine": "migrate.sh", "order": "first"})
+	p.Add("file.File", "config", nil)
+	p.AddData("cmd.Command", "script", "run.sh", []byte("echo"), map[string]interface{}{"commandLine": "run.sh", "bogus": true})
+	p.Add("udm.BaseDeployable", "virtual", nil)
+	p.AddData("file.File", "ok", "ok.txt", []byte("ok"), map[string]interface{}{"targetFileName": "ok.txt"})
+
+	err := p.Validate(client.Meta)
+
+	be, ok := err.(*xld.BatchValidationError)
+	if !ok {
+		t.Fatalf("Expected a *xld.BatchValidationError but got %v", err)
+	}
+
+	expected := []string{
+		"order",
+		"file",
+		"file, bogus",
+		"type",
+		"",
+	}
+
+	for i, e := range be.Errors {
+		var props []string
+		if e != nil {
+			for _, m := range e.Messages {
+				props = append(props, m.Property)
+			}
+		}
+
+		if strings.Join(props, ", ") != expected[i] {
+			t.Errorf("Expected deployable %v to fail on %v but got %v", p.Deployables[i].Name, expected[i], e)
+		}
+	}
+}
+
+var mockTestTypes = `[
+  {"type": "udm.Deployable", "virtual": true},
+  {"type": "udm.Artifact", "virtual": true},
+  {"type": "udm.BaseDeployable", "virtual": true, "interfaces": ["udm.Deployable"]},
+  {"type": "cmd.Command", "superTypes": ["udm.BaseDeployable"], "properties": [
+    {"name": "commandLine", "kind": "STRING", "required": true},
+    {"name": "order", "kind": "INTEGER", "default": 50},
+    {"name": "dependencies", "kind": "SET_OF_CI", "referencedType": "udm.Deployable"},
+    {"name": "tags", "kind": "SET_OF_STRING"}
+  ]},
+  {"type": "file.File", "superTypes": ["udm.BaseDeployable"], "interfaces": ["udm.Artifact"], "properties": [
+    {"name": "targetFileName", "kind": "STRING"},
+    {"name": "placeholders", "kind": "SET_OF_STRING"},
+    {"name": "checksum", "kind": "STRING"}
+  ]},
+  {"type": "file.Folder", "superTypes": ["udm.BaseDeployable"], "interfaces": ["udm.Artifact"], "properties": [
+    {"name": "targetPath", "kind": "STRING"}
+  ]}
+]`
@@ -0,0 +1,511 @@
+package dar
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wianvos/xld"
+)
+
+//Archive is a dar opened for inspection
+type Archive struct {
+	Application string
+	Version     string
+	Properties  map[string]interface{}
+	Deployables []ArchiveDeployable
+	// Missing lists the files the manifest refers to that are not in the dar
+	Missing []string
+
+	z      *zip.Reader
+	closer io.Closer
+}
+
+//ArchiveDeployable is a deployable as the manifest of an archive describes it
+// File is the artifact path in the dar and Entries the files that belong to it, both are empty for non artifacts
+type ArchiveDeployable struct {
+	Name    string
+	Ci      xld.Ci
+	File    string
+	Entries []string
+}
+
+//placeholderPattern matches the {{NAME}} placeholders xldeploy replaces at deployment time
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+//Open opens the dar at path and parses its manifest
+// the entries are read from the file when they are needed, Close the archive when done with it
+func Open(path string) (*Archive, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newArchive(&z.Reader)
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+	a.closer = z
+
+	return a, nil
+}
+
+//Read reads a dar of size bytes from r and parses its manifest
+func Read(r io.ReaderAt, size int64) (*Archive, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return newArchive(z)
+}
+
+//Close closes the file of an archive returned by Open, for archives returned by Read it does nothing
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+
+	return a.closer.Close()
+}
+
+//ID returns the id the package gets in the xldeploy repository
+func (a *Archive) ID() string {
+	return "Applications/" + a.Application + "/" + a.Version
+}
+
+//Deployable returns the deployable with name n
+func (a *Archive) Deployable(n string) (ArchiveDeployable, bool) {
+	for _, d := range a.Deployables {
+		if d.Name == n {
+			return d, true
+		}
+	}
+
+	return ArchiveDeployable{}, false
+}
+
+//OpenEntry opens a file in the dar
+func (a *Archive) OpenEntry(n string) (io.ReadCloser, error) {
+	f := a.file(n)
+	if f == nil {
+		return nil, fmt.Errorf("%s not found in dar", n)
+	}
+
+	return f.Open()
+}
+
+//ScanPlaceholders returns per deployable name the sorted placeholders found in its artifact files
+// deployables with scanPlaceholders set to false and binary files are skipped,
+// archives inside the dar (ear, war, jar, zip) are not opened
+func (a *Archive) ScanPlaceholders() (map[string][]string, error) {
+	found := make(map[string][]string)
+
+	for _, d := range a.Deployables {
+		if len(d.Entries) == 0 || fmt.Sprint(d.Ci.Properties["scanPlaceholders"]) == "false" {
+			continue
+		}
+
+		seen := make(map[string]bool)
+
+		for _, e := range d.Entries {
+			b, err := a.read(e)
+			if err != nil {
+				return nil, err
+			}
+
+			if isBinary(b) {
+				continue
+			}
+
+			for _, m := range placeholderPattern.FindAllSubmatch(b, -1) {
+				seen[string(m[1])] = true
+			}
+		}
+
+		if len(seen) == 0 {
+			continue
+		}
+
+		var l []string
+		for p := range seen {
+			l = append(l, p)
+		}
+		sort.Strings(l)
+
+		found[d.Name] = l
+	}
+
+	return found, nil
+}
+
+//Compare returns the differences between two versions of an application
+// deployables are matched on name and references into the packages are compared relative to the package,
+// next to the properties the content of the artifacts is compared by checksum
+func Compare(old, new *Archive) (xld.TreeDiff, error) {
+	d := xld.TreeDiff{Left: old.ID(), Right: new.ID(), Changes: []xld.CiDiff{}}
+
+	ref := func(id string) string {
+		for _, root := range []string{old.ID(), new.ID()} {
+			if strings.HasPrefix(id, root+"/") {
+				return "<package>" + id[len(root):]
+			}
+		}
+		return id
+	}
+
+	left := xld.Ci{ID: old.ID(), Type: "udm.DeploymentPackage", Properties: old.Properties}
+	right := xld.Ci{ID: new.ID(), Type: "udm.DeploymentPackage", Properties: new.Properties}
+	if c := diffCis(left, right, ref); len(c) > 0 {
+		d.Changes = append(d.Changes, xld.CiDiff{Change: xld.DiffChanged, LeftID: left.ID, RightID: right.ID,
+			LeftType: left.Type, RightType: right.Type, Properties: c})
+	}
+
+	names := make(map[string]bool)
+	for _, a := range []*Archive{old, new} {
+		for _, dep := range a.Deployables {
+			names[dep.Name] = true
+		}
+	}
+
+	var sorted []string
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	for _, n := range sorted {
+		l, lok := old.Deployable(n)
+		r, rok := new.Deployable(n)
+
+		switch {
+		case !rok:
+			d.Changes = append(d.Changes, xld.CiDiff{Path: n, Change: xld.DiffRemoved, LeftID: l.Ci.ID, LeftType: l.Ci.Type})
+			continue
+		case !lok:
+			d.Changes = append(d.Changes, xld.CiDiff{Path: n, Change: xld.DiffAdded, RightID: r.Ci.ID, RightType: r.Ci.Type})
+			continue
+		}
+
+		c := xld.CiDiff{Path: n, Change: xld.DiffChanged, LeftID: l.Ci.ID, RightID: r.Ci.ID, LeftType: l.Ci.Type, RightType: r.Ci.Type}
+
+		if l.Ci.Type == r.Ci.Type {
+			lc, err := old.comparable(l)
+			if err != nil {
+				return d, err
+			}
+			rc, err := new.comparable(r)
+			if err != nil {
+				return d, err
+			}
+			c.Properties = diffCis(lc, rc, ref)
+		}
+
+		if l.Ci.Type != r.Ci.Type || len(c.Properties) > 0 {
+			d.Changes = append(d.Changes, c)
+		}
+	}
+
+	return d, nil
+}
+
+//private functions
+
+//newArchive parses the manifest of the dar in z
+func newArchive(z *zip.Reader) (*Archive, error) {
+	a := &Archive{z: z}
+
+	m := a.file(ManifestName)
+	if m == nil {
+		return nil, fmt.Errorf("%s not found in dar", ManifestName)
+	}
+
+	rc, err := m.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	root, err := parseXML(rc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ManifestName, err)
+	}
+
+	a.Application = root.attr("application")
+	a.Version = root.attr("version")
+	a.Properties = make(map[string]interface{})
+
+	for _, c := range root.children {
+		if c.name != "deployables" {
+			a.Properties[c.name] = c.value()
+			continue
+		}
+
+		for _, d := range c.children {
+			a.Deployables = append(a.Deployables, a.deployable(d))
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Archive) deployable(n *xmlNode) ArchiveDeployable {
+	d := ArchiveDeployable{Name: n.attr("name"), File: n.attr("file")}
+	d.Ci = xld.Ci{ID: a.ID() + "/" + d.Name, Type: n.name, Properties: make(map[string]interface{})}
+
+	for _, c := range n.children {
+		d.Ci.Properties[c.name] = c.value()
+	}
+
+	if d.File == "" {
+		return d
+	}
+
+	prefix := strings.TrimSuffix(d.File, "/") + "/"
+	for _, f := range a.z.File {
+		if f.Name == d.File || strings.HasPrefix(f.Name, prefix) && !strings.HasSuffix(f.Name, "/") {
+			d.Entries = append(d.Entries, f.Name)
+		}
+	}
+
+	if len(d.Entries) == 0 {
+		a.Missing = append(a.Missing, d.File)
+	}
+
+	return d
+}
+
+func (a *Archive) file(n string) *zip.File {
+	for _, f := range a.z.File {
+		if f.Name == n {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func (a *Archive) read(n string) ([]byte, error) {
+	rc, err := a.OpenEntry(n)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
+
+//checksum returns the sha1 of the names and contents of the entries of a deployable
+func (a *Archive) checksum(d ArchiveDeployable) (string, error) {
+	if len(d.Entries) == 0 {
+		return "", nil
+	}
+
+	h := sha1.New()
+	for _, e := range d.Entries {
+		rc, err := a.OpenEntry(e)
+		if err != nil {
+			return "", err
+		}
+
+		io.WriteString(h, strings.TrimPrefix(e, d.File))
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//comparable returns the ci of a deployable the way Compare compares it
+// the checksum the manifest records is replaced by a file property with the checksum of the content in the dar
+func (a *Archive) comparable(d ArchiveDeployable) (xld.Ci, error) {
+	sum, err := a.checksum(d)
+	if err != nil {
+		return d.Ci, err
+	}
+
+	c := d.Ci
+	c.Properties = make(map[string]interface{}, len(d.Ci.Properties)+1)
+	for k, v := range d.Ci.Properties {
+		if k != "checksum" {
+			c.Properties[k] = v
+		}
+	}
+	if sum != "" {
+		c.Properties["file"] = sum
+	}
+
+	return c, nil
+}
+
+//diffCis compares two cis read from manifests
+// the references are turned into plain ids so they are normalized by ref like xldeploy references
+func diffCis(left, right xld.Ci, ref func(string) string) []xld.PropertyChange {
+	meta := inferMeta(left, right)
+
+	for _, c := range []*xld.Ci{&left, &right} {
+		props := make(map[string]interface{}, len(c.Properties))
+		for k, v := range c.Properties {
+			props[k] = plainRefs(v)
+		}
+		c.Properties = props
+	}
+
+	return xld.DiffCis(meta, left, right, ref)
+}
+
+//inferMeta derives the property kinds from the values of two cis read from manifests
+// a manifest does not tell sets from lists, lists are compared as sets
+func inferMeta(cis ...xld.Ci) xld.MetaData {
+	var meta xld.MetaData
+	seen := make(map[string]bool)
+
+	for _, c := range cis {
+		for n, v := range c.Properties {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+
+			kind := xld.KindString
+			switch v.(type) {
+			case Ref:
+				kind = xld.KindCi
+			case []Ref:
+				kind = xld.KindSetOfCi
+			case []string:
+				kind = xld.KindSetOfString
+			case map[string]string:
+				kind = xld.KindMapStringString
+			}
+
+			meta.Properties = append(meta.Properties, xld.Property{Name: n, Kind: kind})
+		}
+	}
+
+	sort.Sort(byProperty(meta.Properties))
+
+	return meta
+}
+
+type byProperty []xld.Property
+
+func (b byProperty) Len() int           { return len(b) }
+func (b byProperty) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byProperty) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+//isBinary guesses whether b is the content of a binary file by looking for a nul byte in its start
+func isBinary(b []byte) bool {
+	if len(b) > 512 {
+		b = b[:512]
+	}
+
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+//xmlNode is an element of the manifest
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*xmlNode
+}
+
+func parseXML(r io.Reader) (*xmlNode, error) {
+	d := xml.NewDecoder(r)
+
+	var stack []*xmlNode
+	var root *xmlNode
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+
+	return root, nil
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+//value interprets a property element of the manifest like xldeploy does
+// <p ref=".."/> is a reference, <value> children a list of strings, <ci ref=".."> children
+// a list of references and <entry key=".."> children a map
+func (n *xmlNode) value() interface{} {
+	if ref := n.attr("ref"); ref != "" {
+		return Ref(ref)
+	}
+
+	if len(n.children) == 0 {
+		return strings.TrimSpace(n.text)
+	}
+
+	switch n.children[0].name {
+	case "value":
+		l := make([]string, len(n.children))
+		for i, c := range n.children {
+			l[i] = strings.TrimSpace(c.text)
+		}
+		return l
+	case "ci":
+		l := make([]Ref, len(n.children))
+		for i, c := range n.children {
+			l[i] = Ref(c.attr("ref"))
+		}
+		return l
+	case "entry":
+		m := make(map[string]string, len(n.children))
+		for _, c := range n.children {
+			m[c.attr("key")] = strings.TrimSpace(c.text)
+		}
+		return m
+	}
+
+	return strings.TrimSpace(n.text)
+}
@@ -0,0 +1,163 @@
+package dar
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func buildTestPackage(t *testing.T, version, config string, extra bool) *Archive {
+	p := New("PetClinic", version)
+	p.Properties["orchestrator"] = []string{"sequential-by-container"}
+	p.Add("cmd.Command", "migrate", map[string]interface{}{
+		"commandLine":  "migrate.sh " + version,
+		"dependencies": []Ref{Ref(p.ID() + "/config")},
+	})
+	p.AddData("file.File", "config", "app.properties", []byte(config), map[string]interface{}{
+		"placeholders": []string{"DB_URL"},
+	})
+	p.AddData("file.File", "binary", "logo.png", []byte("\x89PNG\x00{{NOT_A_PLACEHOLDER}}"), nil)
+	if extra {
+		p.Add("cmd.Command", "cleanup", map[string]interface{}{"commandLine": "cleanup.sh"})
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	a, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+
+	return a
+}
+
+func TestRead(t *testing.T) {
+	a := buildTestPackage(t, "1.0", "url={{DB_URL}}\nuser={{DB_USER}}", false)
+
+	if a.ID() != "Applications/PetClinic/1.0" || len(a.Deployables) != 3 || len(a.Missing) != 0 {
+		t.Fatalf("Read returned %+v", a)
+	}
+
+	if !reflect.DeepEqual(a.Properties["orchestrator"], []string{"sequential-by-container"}) {
+		t.Errorf("Expected the package properties to be read but got %v", a.Properties)
+	}
+
+	m, _ := a.Deployable("migrate")
+	if m.Ci.Type != "cmd.Command" || m.Ci.ID != "Applications/PetClinic/1.0/migrate" || m.File != "" {
+		t.Errorf("Unexpected deployable %+v", m)
+	}
+
+	if !reflect.DeepEqual(m.Ci.Properties["dependencies"], []Ref{"Applications/PetClinic/1.0/config"}) {
+		t.Errorf("Expected the dependencies to be read as references but got %#v", m.Ci.Properties["dependencies"])
+	}
+
+	c, _ := a.Deployable("config")
+	if c.File != "config/app.properties" || !reflect.DeepEqual(c.Entries, []string{"config/app.properties"}) {
+		t.Errorf("Unexpected artifact %+v", c)
+	}
+
+	p, err := a.ScanPlaceholders()
+	if err != nil {
+		t.Fatalf("ScanPlaceholders returned error: %v", err)
+	}
+
+	expected := map[string][]string{"config": {"DB_URL", "DB_USER"}}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("ScanPlaceholders returned %v, expected %v", p, expected)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := New("PetClinic", "1.0")
+	p.AddData("file.File", "config", "app.properties", []byte("url={{DB_URL}}"), nil)
+
+	path := filepath.Join(dir, "PetClinic-1.0.dar")
+	if err := p.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	a, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	found, err := a.ScanPlaceholders()
+	if err != nil || !reflect.DeepEqual(found, map[string][]string{"config": {"DB_URL"}}) {
+		t.Errorf("ScanPlaceholders returned %v, %v", found, err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Errorf("Close returned error: %v", err)
+	}
+
+	if _, err := Open(filepath.Join(dir, "missing.dar")); err == nil {
+		t.Errorf("Expected an error for a missing dar")
+	}
+}
+
+func TestReadMissingFile(t *testing.T) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	w, _ := z.Create(ManifestName)
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<udm.DeploymentPackage version="1.0" application="PetClinic">
+  <deployables>
+    <file.File name="config" file="config/app.properties"/>
+  </deployables>
+</udm.DeploymentPackage>`))
+	z.Close()
+
+	a, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(a.Missing, []string{"config/app.properties"}) {
+		t.Errorf("Expected config/app.properties to be missing but got %v", a.Missing)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old := buildTestPackage(t, "1.0", "url={{DB_URL}}", false)
+	new := buildTestPackage(t, "2.0", "url={{DB_URL}}\npool=10", true)
+
+	d, err := Compare(old, new)
+	if err != nil {
+		t.Fatalf("Compare returned error: %v", err)
+	}
+
+	text := d.Text()
+
+	for _, expected := range []string{
+		"--- Applications/PetClinic/1.0\n+++ Applications/PetClinic/2.0\n",
+		"+ cleanup (cmd.Command)\n",
+		"~ config (file.File)\n    file: ",
+		"~ migrate (cmd.Command)\n    commandLine: migrate.sh 1.0 -> migrate.sh 2.0\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected the comparison to contain %q but got\n%v", expected, text)
+		}
+	}
+
+	if strings.Count(text, "config") != 1 || strings.Contains(text, "checksum") {
+		t.Errorf("Expected the changed artifact to be reported once but got\n%v", text)
+	}
+
+	if strings.Contains(text, "dependencies") || strings.Contains(text, "binary") {
+		t.Errorf("Expected references into the package and unchanged artifacts to be equal but got\n%v", text)
+	}
+}
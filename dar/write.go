@@ -0,0 +1,354 @@
+package dar
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wianvos/xld"
+)
+
+//Write writes the package as a dar to w
+// the artifacts are written first so their checksums can be put in the manifest
+func (p *Package) Write(w io.Writer) error {
+	if p.Application == "" || p.Version == "" {
+		return fmt.Errorf("a package needs an application and a version")
+	}
+
+	z := zip.NewWriter(w)
+
+	checksums := make(map[*Deployable]string)
+
+	for _, d := range p.Deployables {
+		if d.Artifact == nil {
+			continue
+		}
+
+		sum, err := writeArtifact(z, d)
+		if err != nil {
+			return fmt.Errorf("artifact %s: %v", d.Name, err)
+		}
+
+		if sum != "" {
+			checksums[d] = sum
+		}
+	}
+
+	m, err := z.Create(ManifestName)
+	if err != nil {
+		return err
+	}
+
+	if err := p.writeManifest(m, checksums); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+//WriteFile writes the package as a dar to the file at path
+func (p *Package) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+//Manifest returns the deployit-manifest.xml of the package, without artifact checksums
+func (p *Package) Manifest() ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := p.writeManifest(&buf, nil)
+
+	return buf.Bytes(), err
+}
+
+//private functions
+
+//writeArtifact adds the file or folder of d to the zip and returns the sha1 checksum of a file
+// folders get no checksum, xldeploy computes those itself
+func writeArtifact(z *zip.Writer, d *Deployable) (string, error) {
+	a := d.Artifact
+	name := d.file()
+
+	if a.Path == "" {
+		return writeEntry(z, name, bytes.NewReader(a.Data))
+	}
+
+	fi, err := os.Stat(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.IsDir() {
+		f, err := os.Open(a.Path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		return writeEntry(z, name, f)
+	}
+
+	err = filepath.Walk(a.Path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(a.Path, p)
+		if err != nil {
+			return err
+		}
+
+		entry := path.Join(name, filepath.ToSlash(rel))
+
+		if fi.IsDir() {
+			_, err := z.Create(entry + "/")
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = writeEntry(z, entry, f)
+		return err
+	})
+
+	return "", err
+}
+
+func writeEntry(z *zip.Writer, name string, r io.Reader) (string, error) {
+	w, err := z.Create(name)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *Package) writeManifest(w io.Writer, checksums map[*Deployable]string) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+
+	root := xml.StartElement{Name: xml.Name{Local: "udm.DeploymentPackage"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "version"}, Value: p.Version},
+		{Name: xml.Name{Local: "application"}, Value: p.Application},
+	}}
+
+	if err := e.EncodeToken(root); err != nil {
+		return err
+	}
+
+	if err := p.writeProperties(e, "udm.DeploymentPackage", p.Properties); err != nil {
+		return err
+	}
+
+	deployables := xml.StartElement{Name: xml.Name{Local: "deployables"}}
+	if err := e.EncodeToken(deployables); err != nil {
+		return err
+	}
+
+	for _, d := range p.Deployables {
+		start := xml.StartElement{Name: xml.Name{Local: d.Type}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: d.Name}}}
+		if d.Artifact != nil {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "file"}, Value: d.file()})
+		}
+
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+
+		props := make(map[string]interface{}, len(d.Properties)+3)
+		for k, v := range d.Properties {
+			props[k] = v
+		}
+		if len(d.Tags) > 0 {
+			props["tags"] = d.Tags
+		}
+		if len(d.Placeholders) > 0 {
+			props["placeholders"] = d.Placeholders
+		}
+		if sum, ok := checksums[d]; ok {
+			props["checksum"] = sum
+		}
+
+		if err := p.writeProperties(e, d.Type, props); err != nil {
+			return fmt.Errorf("deployable %s: %v", d.Name, err)
+		}
+
+		if err := e.EncodeToken(start.End()); err != nil {
+			return err
+		}
+	}
+
+	if err := e.EncodeToken(deployables.End()); err != nil {
+		return err
+	}
+
+	if err := e.EncodeToken(root.End()); err != nil {
+		return err
+	}
+
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+//writeProperties writes the properties sorted by name
+// the kinds learned by Validate decide how a value is written, without them the go type of the value does
+func (p *Package) writeProperties(e *xml.Encoder, t string, props map[string]interface{}) error {
+	var names []string
+	for n, v := range props {
+		if v != nil {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		v := props[n]
+
+		if kind, ok := p.kinds[t][n]; ok {
+			cv, err := xld.ConvertProperty(kind, plainRefs(v))
+			if err != nil {
+				return fmt.Errorf("property %s: %v", n, err)
+			}
+
+			switch kind {
+			case xld.KindCi:
+				v = Ref(cv.(string))
+			case xld.KindSetOfCi, xld.KindListOfCi:
+				l := make([]Ref, len(cv.([]string)))
+				for i, id := range cv.([]string) {
+					l[i] = Ref(id)
+				}
+				v = l
+			default:
+				v = cv
+			}
+		}
+
+		if err := writeProperty(e, n, v); err != nil {
+			return fmt.Errorf("property %s: %v", n, err)
+		}
+	}
+
+	return nil
+}
+
+func writeProperty(e *xml.Encoder, n string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: n}}
+
+	switch v := v.(type) {
+	case Ref:
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: string(v)}}
+		return encodeElement(e, start, nil)
+	case []Ref:
+		return encodeElement(e, start, func() error {
+			for _, r := range v {
+				ci := xml.StartElement{Name: xml.Name{Local: "ci"}, Attr: []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: string(r)}}}
+				if err := encodeElement(e, ci, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case []string:
+		return encodeElement(e, start, func() error { return writeValues(e, v) })
+	case []interface{}:
+		l := make([]string, len(v))
+		for i, s := range v {
+			l[i] = fmt.Sprint(s)
+		}
+		return encodeElement(e, start, func() error { return writeValues(e, l) })
+	case map[string]string:
+		return encodeElement(e, start, func() error { return writeEntries(e, v) })
+	case map[string]interface{}:
+		m := make(map[string]string, len(v))
+		for k, s := range v {
+			m[k] = fmt.Sprint(s)
+		}
+		return encodeElement(e, start, func() error { return writeEntries(e, m) })
+	case string, bool, int, int64, float64:
+		return e.EncodeElement(fmt.Sprint(v), start)
+	}
+
+	return fmt.Errorf("can not write %T to a manifest", v)
+}
+
+func writeValues(e *xml.Encoder, l []string) error {
+	for _, s := range l {
+		if err := e.EncodeElement(s, xml.StartElement{Name: xml.Name{Local: "value"}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeEntries(e *xml.Encoder, m map[string]string) error {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		entry := xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}}}
+		if err := e.EncodeElement(m[k], entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//encodeElement writes start, the children written by body and the end of start
+func encodeElement(e *xml.Encoder, start xml.StartElement, body func() error) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if body != nil {
+		if err := body(); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+//isPlaceholder returns true for values like {{DB_URL}} that are filled in by xldeploy at deployment time
+func isPlaceholder(s string) bool {
+	return strings.HasPrefix(s, "{{") && strings.HasSuffix(s, "}}")
+}
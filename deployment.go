@@ -0,0 +1,220 @@
+package xld
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+)
+
+const (
+	deploymentBasePath = "deployit/deployment"
+)
+
+//Deployment types as returned by xldeploy
+const (
+	DeploymentInitial  = "INITIAL"
+	DeploymentUpdate   = "UPDATE"
+	DeploymentUndeploy = "UNDEPLOYMENT"
+)
+
+//DeploymentService represents the service for engaging the XL-Deploy deployment rest interface
+type DeploymentService interface {
+	PrepareInitial(v, e string) (Deployment, error)
+	PrepareInitialContext(ctx context.Context, v, e string) (Deployment, error)
+	PrepareUpdate(v, a string) (Deployment, error)
+	PrepareUpdateContext(ctx context.Context, v, a string) (Deployment, error)
+	PrepareUndeploy(a string) (Deployment, error)
+	PrepareUndeployContext(ctx context.Context, a string) (Deployment, error)
+	PrepareDeployeds(d Deployment) (Deployment, error)
+	PrepareDeployedsContext(ctx context.Context, d Deployment) (Deployment, error)
+	Validate(d Deployment) (Deployment, error)
+	ValidateContext(ctx context.Context, d Deployment) (Deployment, error)
+	Deploy(d Deployment) (string, error)
+	DeployContext(ctx context.Context, d Deployment) (string, error)
+}
+
+//DeploymentServiceOp holds the communication service for the Deployment rest api
+type DeploymentServiceOp struct {
+	client *Client
+}
+
+var _ DeploymentService = &DeploymentServiceOp{}
+
+//Deployment is the specification of a deployment as prepared by xldeploy
+// it can be edited before it is validated and turned into a task
+type Deployment struct {
+	ID                  string       `json:"id,omitempty"`
+	Type                string       `json:"type"`
+	Application         Ci           `json:"application"`
+	Deployeds           []Ci         `json:"deployeds"`
+	Deployables         []Ci         `json:"deployables,omitempty"`
+	Containers          []Ci         `json:"containers,omitempty"`
+	RequiredDeployments []Deployment `json:"requiredDeployments,omitempty"`
+}
+
+//PrepareInitial prepares the initial deployment of a version to an environment
+// v: id of the deployment package (Applications/App/1.0)
+// e: id of the environment (Environments/Dev)
+func (d DeploymentServiceOp) PrepareInitial(v, e string) (Deployment, error) {
+	return d.PrepareInitialContext(context.Background(), v, e)
+}
+
+//PrepareInitialContext is PrepareInitial with a context that is attached to every request it makes
+func (d DeploymentServiceOp) PrepareInitialContext(ctx context.Context, v, e string) (Deployment, error) {
+
+	q := url.Values{}
+	q.Set("version", v)
+	q.Set("environment", e)
+
+	return d.prepare(ctx, "initial", q)
+}
+
+//PrepareUpdate prepares an update of an already deployed application to a new version
+// v: id of the deployment package to update to
+// a: id of the deployed application (Environments/Dev/App)
+func (d DeploymentServiceOp) PrepareUpdate(v, a string) (Deployment, error) {
+	return d.PrepareUpdateContext(context.Background(), v, a)
+}
+
+//PrepareUpdateContext is PrepareUpdate with a context that is attached to every request it makes
+func (d DeploymentServiceOp) PrepareUpdateContext(ctx context.Context, v, a string) (Deployment, error) {
+
+	q := url.Values{}
+	q.Set("version", v)
+	q.Set("deployedApplication", a)
+
+	return d.prepare(ctx, "update", q)
+}
+
+//PrepareUndeploy prepares the undeployment of a deployed application
+// a: id of the deployed application (Environments/Dev/App)
+func (d DeploymentServiceOp) PrepareUndeploy(a string) (Deployment, error) {
+	return d.PrepareUndeployContext(context.Background(), a)
+}
+
+//PrepareUndeployContext is PrepareUndeploy with a context that is attached to every request it makes
+func (d DeploymentServiceOp) PrepareUndeployContext(ctx context.Context, a string) (Deployment, error) {
+
+	q := url.Values{}
+	q.Set("deployedApplication", a)
+
+	return d.prepare(ctx, "undeploy", q)
+}
+
+//PrepareDeployeds generates the deployeds for a prepared deployment
+func (d DeploymentServiceOp) PrepareDeployeds(dep Deployment) (Deployment, error) {
+	return d.PrepareDeployedsContext(context.Background(), dep)
+}
+
+//PrepareDeployedsContext is PrepareDeployeds with a context that is attached to every request it makes
+func (d DeploymentServiceOp) PrepareDeployedsContext(ctx context.Context, dep Deployment) (Deployment, error) {
+
+	return d.post(ctx, deploymentBasePath+"/prepare/deployeds", dep)
+}
+
+//Validate lets xldeploy validate a deployment
+// the returned deployment carries the validation results
+func (d DeploymentServiceOp) Validate(dep Deployment) (Deployment, error) {
+	return d.ValidateContext(context.Background(), dep)
+}
+
+//ValidateContext is Validate with a context that is attached to every request it makes
+func (d DeploymentServiceOp) ValidateContext(ctx context.Context, dep Deployment) (Deployment, error) {
+
+	return d.post(ctx, deploymentBasePath+"/validate", dep)
+}
+
+//Deploy turns a deployment into a task and returns the id of that task
+// the task still needs to be started
+func (d DeploymentServiceOp) Deploy(dep Deployment) (string, error) {
+	return d.DeployContext(context.Background(), dep)
+}
+
+//DeployContext is Deploy with a context that is attached to every request it makes
+func (d DeploymentServiceOp) DeployContext(ctx context.Context, dep Deployment) (string, error) {
+
+	req, err := d.client.NewRequestContext(ctx, deploymentBasePath, "POST", dep)
+	if err != nil {
+		return "", err
+	}
+
+	// xldeploy answers with the bare task id
+	id := new(bytes.Buffer)
+
+	_, err = d.client.Do(req, id)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(strings.TrimSpace(id.String()), "\""), nil
+}
+
+//Environment returns the id of the environment the deployment is targeted at
+func (d Deployment) Environment() string {
+	e, _ := d.Application.Properties["environment"].(string)
+	return e
+}
+
+//Version returns the id of the deployment package that is being deployed
+func (d Deployment) Version() string {
+	v, _ := d.Application.Properties["version"].(string)
+	return v
+}
+
+//Orchestrators returns the orchestrators set on the deployed application
+func (d Deployment) Orchestrators() []string {
+	var o []string
+
+	switch v := d.Application.Properties["orchestrator"].(type) {
+	case []string:
+		o = append(o, v...)
+	case []interface{}:
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				o = append(o, s)
+			}
+		}
+	}
+
+	return o
+}
+
+//SetOrchestrators sets the orchestrators that xldeploy should use to plan the deployment
+func (d *Deployment) SetOrchestrators(o ...string) {
+	if d.Application.Properties == nil {
+		d.Application.Properties = make(map[string]interface{})
+	}
+
+	d.Application.Properties["orchestrator"] = o
+}
+
+//private functions
+
+func (d DeploymentServiceOp) prepare(ctx context.Context, kind string, q url.Values) (Deployment, error) {
+	var dep Deployment
+
+	url := deploymentBasePath + "/prepare/" + kind + "?" + q.Encode()
+
+	req, err := d.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return dep, err
+	}
+
+	_, err = d.client.Do(req, &dep)
+
+	return dep, err
+}
+
+func (d DeploymentServiceOp) post(ctx context.Context, url string, dep Deployment) (Deployment, error) {
+	var rd Deployment
+
+	req, err := d.client.NewRequestContext(ctx, url, "POST", dep)
+	if err != nil {
+		return rd, err
+	}
+
+	_, err = d.client.Do(req, &rd)
+
+	return rd, err
+}
@@ -0,0 +1,171 @@
+package xld
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareInitial(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/deployment/prepare/initial", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+
+		if v := r.URL.Query().Get("version"); v != "Applications/testApp/1.0" {
+			t.Errorf("Expected version Applications/testApp/1.0 but got %v", v)
+		}
+		if e := r.URL.Query().Get("environment"); e != "Environments/testEnv" {
+			t.Errorf("Expected environment Environments/testEnv but got %v", e)
+		}
+
+		fmt.Fprint(w, mockTestDeploymentResponse)
+	})
+
+	d, err := client.Deployment.PrepareInitial("Applications/testApp/1.0", "Environments/testEnv")
+	if err != nil {
+		t.Errorf("Deployment.PrepareInitial returned error: %v", err)
+	}
+
+	if d.Type != DeploymentInitial {
+		t.Errorf("Expected type %v but got %v", DeploymentInitial, d.Type)
+	}
+
+	if d.Application.ID != "Environments/testEnv/testApp" {
+		t.Errorf("Expected application Environments/testEnv/testApp but got %v", d.Application.ID)
+	}
+
+	if d.Environment() != "Environments/testEnv" {
+		t.Errorf("Expected environment Environments/testEnv but got %v", d.Environment())
+	}
+
+	if d.Version() != "Applications/testApp/1.0" {
+		t.Errorf("Expected version Applications/testApp/1.0 but got %v", d.Version())
+	}
+
+	if !reflect.DeepEqual(d.Orchestrators(), []string{"sequential-by-container"}) {
+		t.Errorf("Expected orchestrators [sequential-by-container] but got %v", d.Orchestrators())
+	}
+}
+
+func TestPrepareDeployeds(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/deployment/prepare/deployeds", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var d Deployment
+		json.NewDecoder(r.Body).Decode(&d)
+
+		if !reflect.DeepEqual(d.Orchestrators(), []string{"parallel-by-container"}) {
+			t.Errorf("Expected orchestrators to be sent, got %v", d.Orchestrators())
+		}
+
+		fmt.Fprint(w, mockTestDeploymentWithDeployedsResponse)
+	})
+
+	var d Deployment
+	json.Unmarshal([]byte(mockTestDeploymentResponse), &d)
+	d.SetOrchestrators("parallel-by-container")
+
+	d, err := client.Deployment.PrepareDeployeds(d)
+	if err != nil {
+		t.Errorf("Deployment.PrepareDeployeds returned error: %v", err)
+	}
+
+	if len(d.Deployeds) != 1 {
+		t.Fatalf("Expected 1 deployed but got %v", len(d.Deployeds))
+	}
+
+	expected := Ci{
+		ID:   "Infrastructure/testHost/testFile",
+		Type: "file.DeployedFile",
+		Properties: map[string]interface{}{
+			"deployable":       "Applications/testApp/1.0/testFile",
+			"container":        "Infrastructure/testHost",
+			"targetPath":       "/tmp",
+			"createTargetPath": true,
+		},
+	}
+
+	if !reflect.DeepEqual(d.Deployeds[0], expected) {
+		t.Errorf("Expected deployed %+v but got %+v", expected, d.Deployeds[0])
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/deployment", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprint(w, "1b5c4a8e-1a2b-4c3d-8e9f-0a1b2c3d4e5f")
+	})
+
+	var d Deployment
+	json.Unmarshal([]byte(mockTestDeploymentWithDeployedsResponse), &d)
+
+	id, err := client.Deployment.Deploy(d)
+	if err != nil {
+		t.Errorf("Deployment.Deploy returned error: %v", err)
+	}
+
+	if id != "1b5c4a8e-1a2b-4c3d-8e9f-0a1b2c3d4e5f" {
+		t.Errorf("Expected task id 1b5c4a8e-1a2b-4c3d-8e9f-0a1b2c3d4e5f but got %v", id)
+	}
+}
+
+var mockTestDeploymentResponse = `{
+  "id": "deployment-5f8b9a06-1a3e-4a5b-9a0c-3c3e1b2a4d5e",
+  "type": "INITIAL",
+  "application": {
+    "id": "Environments/testEnv/testApp",
+    "type": "udm.DeployedApplication",
+    "version": "Applications/testApp/1.0",
+    "environment": "Environments/testEnv",
+    "orchestrator": ["sequential-by-container"],
+    "optimizePlan": true
+  },
+  "deployeds": [],
+  "deployables": [
+    {"id": "Applications/testApp/1.0/testFile", "type": "file.File"}
+  ],
+  "containers": [
+    {"id": "Infrastructure/testHost", "type": "overthere.SshHost"}
+  ],
+  "requiredDeployments": []
+}`
+
+var mockTestDeploymentWithDeployedsResponse = `{
+  "id": "deployment-5f8b9a06-1a3e-4a5b-9a0c-3c3e1b2a4d5e",
+  "type": "INITIAL",
+  "application": {
+    "id": "Environments/testEnv/testApp",
+    "type": "udm.DeployedApplication",
+    "version": "Applications/testApp/1.0",
+    "environment": "Environments/testEnv",
+    "orchestrator": ["parallel-by-container"],
+    "optimizePlan": true
+  },
+  "deployeds": [
+    {
+      "id": "Infrastructure/testHost/testFile",
+      "type": "file.DeployedFile",
+      "deployable": "Applications/testApp/1.0/testFile",
+      "container": "Infrastructure/testHost",
+      "targetPath": "/tmp",
+      "createTargetPath": true
+    }
+  ],
+  "deployables": [
+    {"id": "Applications/testApp/1.0/testFile", "type": "file.File"}
+  ],
+  "containers": [
+    {"id": "Infrastructure/testHost", "type": "overthere.SshHost"}
+  ],
+  "requiredDeployments": []
+}`
@@ -0,0 +1,423 @@
+package xld
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//DiffKind tells how something differs between the left and the right side of a diff
+type DiffKind string
+
+//The differences a diff can report
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+//maskedPassword is shown instead of the value of password properties
+const maskedPassword = "********"
+
+//PropertyChange is a single difference in a property between two cis
+// sets report every added or removed element and maps every added, removed or changed key on its own
+type PropertyChange struct {
+	Name   string      `json:"name"`
+	Key    string      `json:"key,omitempty"`
+	Change DiffKind    `json:"change"`
+	Left   interface{} `json:"left,omitempty"`
+	Right  interface{} `json:"right,omitempty"`
+}
+
+//CiDiff is the difference between two cis
+// Path is the id relative to the root of the tree, it is empty for the roots themselves
+type CiDiff struct {
+	Path       string           `json:"path"`
+	Change     DiffKind         `json:"change"`
+	LeftID     string           `json:"leftId,omitempty"`
+	RightID    string           `json:"rightId,omitempty"`
+	LeftType   string           `json:"leftType,omitempty"`
+	RightType  string           `json:"rightType,omitempty"`
+	Properties []PropertyChange `json:"properties,omitempty"`
+}
+
+//TreeDiff holds the differences between two cis or two repository subtrees
+type TreeDiff struct {
+	Left    string   `json:"left"`
+	Right   string   `json:"right"`
+	Changes []CiDiff `json:"changes"`
+}
+
+//DiffCis compares the properties of two cis of the type described by meta
+// sets are compared unordered, maps by key and password properties only on whether they are set.
+// ref normalizes ci references before they are compared, it may be nil
+func DiffCis(meta MetaData, left, right Ci, ref func(id string) string) []PropertyChange {
+	return diffCis(meta, left, right, ref, ref)
+}
+
+//DiffCi compares the cis left and right
+func (r RepositoryServiceOp) DiffCi(left, right string) (TreeDiff, error) {
+	return r.DiffCiContext(context.Background(), left, right)
+}
+
+//DiffCiContext is DiffCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) DiffCiContext(ctx context.Context, left, right string) (TreeDiff, error) {
+	d := TreeDiff{Left: left, Right: right, Changes: []CiDiff{}}
+
+	lc, err := r.GetCiContext(ctx, left)
+	if err != nil {
+		return d, err
+	}
+
+	rc, err := r.GetCiContext(ctx, right)
+	if err != nil {
+		return d, err
+	}
+
+	c, err := r.diffPair(ctx, "", lc, rc, nil, nil)
+	if err != nil {
+		return d, err
+	}
+
+	if c != nil {
+		d.Changes = append(d.Changes, *c)
+	}
+
+	return d, nil
+}
+
+//DiffTree compares the subtrees below left and right
+// cis are matched on their id relative to the root and references to cis inside the
+// trees are compared relative to the root as well
+func (r RepositoryServiceOp) DiffTree(left, right string) (TreeDiff, error) {
+	return r.DiffTreeContext(context.Background(), left, right)
+}
+
+//DiffTreeContext is DiffTree with a context that is attached to every request it makes
+func (r RepositoryServiceOp) DiffTreeContext(ctx context.Context, left, right string) (TreeDiff, error) {
+	d := TreeDiff{Left: left, Right: right, Changes: []CiDiff{}}
+
+	ls, err := r.ExportTreeContext(ctx, left, ExportOptions{})
+	if err != nil {
+		return d, err
+	}
+
+	rs, err := r.ExportTreeContext(ctx, right, ExportOptions{})
+	if err != nil {
+		return d, err
+	}
+
+	lcis := relativeCis(ls)
+	rcis := relativeCis(rs)
+
+	var paths []string
+	for p := range lcis {
+		paths = append(paths, p)
+	}
+	for p := range rcis {
+		if _, ok := lcis[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		lc, lok := lcis[p]
+		rc, rok := rcis[p]
+
+		switch {
+		case !rok:
+			d.Changes = append(d.Changes, CiDiff{Path: p, Change: DiffRemoved, LeftID: lc.ID, LeftType: lc.Type})
+		case !lok:
+			d.Changes = append(d.Changes, CiDiff{Path: p, Change: DiffAdded, RightID: rc.ID, RightType: rc.Type})
+		default:
+			c, err := r.diffPair(ctx, p, lc, rc, relativeRef(left), relativeRef(right))
+			if err != nil {
+				return d, err
+			}
+			if c != nil {
+				d.Changes = append(d.Changes, *c)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+//Empty returns true when both sides are the same
+func (d TreeDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+//Text renders the diff in a human readable form
+func (d TreeDiff) Text() string {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", d.Left, d.Right)
+
+	if d.Empty() {
+		b.WriteString("no differences\n")
+		return b.String()
+	}
+
+	for _, c := range d.Changes {
+		path := c.Path
+		if path == "" {
+			path = "."
+		}
+
+		switch c.Change {
+		case DiffAdded:
+			fmt.Fprintf(&b, "+ %s (%s)\n", path, c.RightType)
+		case DiffRemoved:
+			fmt.Fprintf(&b, "- %s (%s)\n", path, c.LeftType)
+		default:
+			if c.LeftType != c.RightType {
+				fmt.Fprintf(&b, "~ %s (%s -> %s)\n", path, c.LeftType, c.RightType)
+			} else {
+				fmt.Fprintf(&b, "~ %s (%s)\n", path, c.LeftType)
+			}
+		}
+
+		for _, p := range c.Properties {
+			name := p.Name
+			if p.Key != "" {
+				name = name + "[" + p.Key + "]"
+			}
+
+			switch p.Change {
+			case DiffAdded:
+				fmt.Fprintf(&b, "    %s: + %v\n", name, p.Right)
+			case DiffRemoved:
+				fmt.Fprintf(&b, "    %s: - %v\n", name, p.Left)
+			default:
+				fmt.Fprintf(&b, "    %s: %v -> %v\n", name, p.Left, p.Right)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+//JSON renders the diff as indented json
+func (d TreeDiff) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+//private functions
+
+//diffPair compares two cis that are matched with each other, it returns nil when they are the same
+func (r RepositoryServiceOp) diffPair(ctx context.Context, path string, left, right Ci, lref, rref func(string) string) (*CiDiff, error) {
+	c := &CiDiff{Path: path, Change: DiffChanged, LeftID: left.ID, RightID: right.ID, LeftType: left.Type, RightType: right.Type}
+
+	// cis of different types are not compared property by property
+	if left.Type != right.Type {
+		return c, nil
+	}
+
+	meta, err := r.client.Meta.GetTypeContext(ctx, left.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Properties = diffCis(meta, left, right, lref, rref)
+	if len(c.Properties) == 0 {
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+func diffCis(meta MetaData, left, right Ci, lref, rref func(string) string) []PropertyChange {
+	var changes []PropertyChange
+
+	// the properties are reported in the order of the metadata, unknown ones alphabetically after them
+	var names []string
+	known := make(map[string]Property, len(meta.Properties))
+	for _, p := range meta.Properties {
+		known[p.Name] = p
+		names = append(names, p.Name)
+	}
+
+	var unknown []string
+	for _, props := range []map[string]interface{}{left.Properties, right.Properties} {
+		for n := range props {
+			if _, ok := known[n]; !ok && !containsString(unknown, n) {
+				unknown = append(unknown, n)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	names = append(names, unknown...)
+
+	for _, n := range names {
+		p := known[n]
+		lv, rv := left.Properties[n], right.Properties[n]
+
+		if p.Password {
+			ls, rs := maskPassword(lv), maskPassword(rv)
+			if ls != rs {
+				changes = append(changes, scalarChange(n, ls, rs))
+			}
+			continue
+		}
+
+		lc := diffValue(p.Kind, lv, lref)
+		rc := diffValue(p.Kind, rv, rref)
+
+		switch p.Kind {
+		case KindSetOfString, KindSetOfCi:
+			changes = append(changes, diffSets(n, lc, rc)...)
+		case KindMapStringString:
+			changes = append(changes, diffMaps(n, lc, rc)...)
+		default:
+			if !reflect.DeepEqual(lc, rc) {
+				changes = append(changes, scalarChange(n, lc, rc))
+			}
+		}
+	}
+
+	return changes
+}
+
+//diffValue brings a property value in a form that can be compared with reflect.DeepEqual
+func diffValue(kind string, v interface{}, ref func(string) string) interface{} {
+	c, err := canonicalProperty(kind, v)
+	if err != nil {
+		// values that do not fit the kind are compared as they are
+		return v
+	}
+
+	if ref == nil || c == nil {
+		return c
+	}
+
+	switch kind {
+	case KindCi:
+		return ref(c.(string))
+	case KindSetOfCi, KindListOfCi:
+		l := make([]string, len(c.([]string)))
+		for i, id := range c.([]string) {
+			l[i] = ref(id)
+		}
+		if kind == KindSetOfCi {
+			sort.Strings(l)
+		}
+		return l
+	}
+
+	return c
+}
+
+func scalarChange(n string, l, r interface{}) PropertyChange {
+	switch {
+	case l == nil:
+		return PropertyChange{Name: n, Change: DiffAdded, Right: r}
+	case r == nil:
+		return PropertyChange{Name: n, Change: DiffRemoved, Left: l}
+	}
+
+	return PropertyChange{Name: n, Change: DiffChanged, Left: l, Right: r}
+}
+
+func diffSets(n string, l, r interface{}) []PropertyChange {
+	ls, lok := l.([]string)
+	rs, rok := r.([]string)
+
+	if (l != nil && !lok) || (r != nil && !rok) {
+		if reflect.DeepEqual(l, r) {
+			return nil
+		}
+		return []PropertyChange{scalarChange(n, l, r)}
+	}
+
+	var changes []PropertyChange
+
+	add, remove := diffStrings(ls, rs)
+	for _, e := range remove {
+		changes = append(changes, PropertyChange{Name: n, Change: DiffRemoved, Left: e})
+	}
+	for _, e := range add {
+		changes = append(changes, PropertyChange{Name: n, Change: DiffAdded, Right: e})
+	}
+
+	return changes
+}
+
+func diffMaps(n string, l, r interface{}) []PropertyChange {
+	lm, lok := l.(map[string]string)
+	rm, rok := r.(map[string]string)
+
+	if (l != nil && !lok) || (r != nil && !rok) {
+		if reflect.DeepEqual(l, r) {
+			return nil
+		}
+		return []PropertyChange{scalarChange(n, l, r)}
+	}
+
+	var keys []string
+	for k := range lm {
+		keys = append(keys, k)
+	}
+	for k := range rm {
+		if _, ok := lm[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []PropertyChange
+
+	for _, k := range keys {
+		lv, lok := lm[k]
+		rv, rok := rm[k]
+
+		switch {
+		case !rok:
+			changes = append(changes, PropertyChange{Name: n, Key: k, Change: DiffRemoved, Left: lv})
+		case !lok:
+			changes = append(changes, PropertyChange{Name: n, Key: k, Change: DiffAdded, Right: rv})
+		case lv != rv:
+			changes = append(changes, PropertyChange{Name: n, Key: k, Change: DiffChanged, Left: lv, Right: rv})
+		}
+	}
+
+	return changes
+}
+
+//maskPassword hides the value of a password property, only whether it is set remains
+func maskPassword(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return nil
+	}
+
+	return maskedPassword
+}
+
+//relativeCis indexes the cis of a snapshot on their id relative to its root
+func relativeCis(s Snapshot) map[string]Ci {
+	m := make(map[string]Ci, len(s.Cis))
+
+	for _, c := range s.Cis {
+		m[strings.TrimPrefix(strings.TrimPrefix(c.ID, s.Root), "/")] = c
+	}
+
+	return m
+}
+
+//relativeRef makes references to cis in the tree below root relative, references outside of it are kept
+func relativeRef(root string) func(string) string {
+	return func(id string) string {
+		if id == root {
+			return "<root>"
+		}
+		if strings.HasPrefix(id, root+"/") {
+			return "<root>" + id[len(root):]
+		}
+		return id
+	}
+}
@@ -0,0 +1,117 @@
+package xld
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiffCis(t *testing.T) {
+	var meta MetaData
+	json.Unmarshal([]byte(mockTestSshHostMetaResponse), &meta)
+
+	left := Ci{ID: "Infrastructure/a", Type: "overthere.SshHost", Properties: map[string]interface{}{
+		"address": "a", "port": "22", "tags": []interface{}{"web", "linux"}, "password": "{b64}abc",
+	}}
+	right := Ci{ID: "Infrastructure/b", Type: "overthere.SshHost", Properties: map[string]interface{}{
+		"address": "b", "port": 22, "tags": []interface{}{"linux", "db"}, "password": "{b64}def",
+	}}
+
+	changes := DiffCis(meta, left, right, nil)
+
+	expected := []PropertyChange{
+		{Name: "address", Change: DiffChanged, Left: "a", Right: "b"},
+		{Name: "tags", Change: DiffRemoved, Left: "web"},
+		{Name: "tags", Change: DiffAdded, Right: "db"},
+	}
+
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Errorf("DiffCis returned %v, expected %v", changes, expected)
+	}
+
+	delete(right.Properties, "password")
+	changes = DiffCis(meta, left, right, nil)
+
+	if len(changes) != 4 || changes[1].Name != "password" || changes[1].Change != DiffRemoved || changes[1].Left != maskedPassword {
+		t.Errorf("Expected a masked removed password but got %+v", changes)
+	}
+}
+
+func TestDiffTree(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTeamAMeta[strings.TrimPrefix(r.URL.Path, "/deployit/metadata/type/")])
+	})
+	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("ancestor") {
+		case "Environments/Test":
+			fmt.Fprint(w, `[{"ref": "Environments/Test/dev", "type": "udm.Environment"},
+				{"ref": "Environments/Test/dict", "type": "udm.Dictionary"},
+				{"ref": "Environments/Test/old", "type": "udm.Dictionary"}]`)
+		default:
+			fmt.Fprint(w, `[{"ref": "Environments/Prod/dev", "type": "udm.Environment"},
+				{"ref": "Environments/Prod/dict", "type": "udm.Dictionary"}]`)
+		}
+	})
+	mux.HandleFunc("/deployit/repository/cis/read", func(w http.ResponseWriter, r *http.Request) {
+		var ids []string
+		json.NewDecoder(r.Body).Decode(&ids)
+
+		if ids[0] == "Environments/Test" {
+			fmt.Fprint(w, mockTestDiffTestCis)
+		} else {
+			fmt.Fprint(w, mockTestDiffProdCis)
+		}
+	})
+
+	d, err := client.Repository.DiffTree("Environments/Test", "Environments/Prod")
+	if err != nil {
+		t.Fatalf("DiffTree returned error: %v", err)
+	}
+
+	expected := `--- Environments/Test
++++ Environments/Prod
+~ dev (udm.Environment)
+    members: - Infrastructure/test1
+    members: + Infrastructure/prod1
+~ dict (udm.Dictionary)
+    entries[db.url]: jdbc:test -> jdbc:prod
+    entries[debug]: - true
+- old (udm.Dictionary)
+`
+
+	if d.Text() != expected {
+		t.Errorf("DiffTree returned\n%v\nexpected\n%v", d.Text(), expected)
+	}
+
+	b, err := d.JSON()
+	if err != nil {
+		t.Fatalf("JSON returned error: %v", err)
+	}
+
+	var rd TreeDiff
+	if err := json.Unmarshal(b, &rd); err != nil || len(rd.Changes) != 3 || rd.Changes[2].Change != DiffRemoved {
+		t.Errorf("Expected the json to hold the 3 changes but got %s (%v)", b, err)
+	}
+}
+
+var mockTestDiffTestCis = `[
+  {"id": "Environments/Test", "type": "core.Directory"},
+  {"id": "Environments/Test/dev", "type": "udm.Environment",
+   "members": ["Infrastructure/test1", "Infrastructure/shared"], "dictionaries": ["Environments/Test/dict"]},
+  {"id": "Environments/Test/dict", "type": "udm.Dictionary",
+   "entries": {"db.url": "jdbc:test", "debug": "true", "app.name": "shop"}, "secret": "{b64}abc"},
+  {"id": "Environments/Test/old", "type": "udm.Dictionary"}
+]`
+
+var mockTestDiffProdCis = `[
+  {"id": "Environments/Prod", "type": "core.Directory"},
+  {"id": "Environments/Prod/dev", "type": "udm.Environment",
+   "members": ["Infrastructure/shared", "Infrastructure/prod1"], "dictionaries": ["Environments/Prod/dict"]},
+  {"id": "Environments/Prod/dict", "type": "udm.Dictionary",
+   "entries": {"db.url": "jdbc:prod", "app.name": "shop"}, "secret": "{b64}def"}
+]`
@@ -0,0 +1,180 @@
+package xld
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//ErrorResponse is returned by Do when xldeploy answers with a non 2xx status code
+// Message holds the body xldeploy sent along, which is usually plain text or html
+type ErrorResponse struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Message    string
+}
+
+func (e *ErrorResponse) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+//ValidationMessage is a single validation failure xldeploy reported for a property of a ci
+type ValidationMessage struct {
+	CiID     string `json:"ci"`
+	Property string `json:"property"`
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+}
+
+//ValidationError is returned when xldeploy refuses a ci because one or more of its properties are invalid
+type ValidationError struct {
+	CiID     string
+	Messages []ValidationMessage
+}
+
+func (e *ValidationError) Error() string {
+	m := make([]string, len(e.Messages))
+
+	for i, v := range e.Messages {
+		m[i] = v.Property + ": " + v.Message
+	}
+
+	return fmt.Sprintf("ci %s is invalid: %s", e.CiID, strings.Join(m, "; "))
+}
+
+//BatchValidationError is returned by the batch operations when xldeploy rejects one or more of the cis
+// Errors is aligned with the cis that were sent, valid cis have a nil entry
+type BatchValidationError struct {
+	Errors []*ValidationError
+}
+
+func (e *BatchValidationError) Error() string {
+	var m []string
+
+	for _, v := range e.Errors {
+		if v != nil {
+			m = append(m, v.Error())
+		}
+	}
+
+	return fmt.Sprintf("%d of %d cis are invalid: %s", len(m), len(e.Errors), strings.Join(m, ", "))
+}
+
+//ConflictError is returned when a ci is saved with a token while it was changed by someone else since it was read
+// for a batch xldeploy does not tell which ci conflicted, IDs then holds all cis that were sent
+type ConflictError struct {
+	ID    string
+	Token string
+	IDs   []string
+}
+
+func (e *ConflictError) Error() string {
+	if e.ID == "" && len(e.IDs) > 0 {
+		return fmt.Sprintf("one of the cis %s was modified since it was read", strings.Join(e.IDs, ", "))
+	}
+
+	return fmt.Sprintf("ci %s was modified since it was read (token %s)", e.ID, e.Token)
+}
+
+//AlreadyExistsError is returned when something is about to be created in xldeploy under an id that is already taken
+type AlreadyExistsError struct {
+	Kind string
+	ID   string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	return fmt.Sprintf("%s %s already exists", e.Kind, e.ID)
+}
+
+//IsAlreadyExists returns true when err is an AlreadyExistsError
+func IsAlreadyExists(err error) bool {
+	_, ok := cause(err).(*AlreadyExistsError)
+	return ok
+}
+
+//IsNotFound returns true when err is an ErrorResponse for a 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+//IsUnauthorized returns true when err is an ErrorResponse for a 401 or a 403
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
+
+//IsConflict returns true when err is a ConflictError or an ErrorResponse for a 409
+func IsConflict(err error) bool {
+	if _, ok := cause(err).(*ConflictError); ok {
+		return true
+	}
+	return hasStatus(err, http.StatusConflict)
+}
+
+//IsValidationError returns true when xldeploy refused the request because its content is invalid
+func IsValidationError(err error) bool {
+	switch cause(err).(type) {
+	case *ValidationError, *BatchValidationError:
+		return true
+	}
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+//private functions
+
+func newErrorResponse(r *http.Response) *ErrorResponse {
+	e := &ErrorResponse{StatusCode: r.StatusCode}
+
+	if r.Request != nil {
+		e.Method = r.Request.Method
+		e.URL = r.Request.URL.String()
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	return e
+}
+
+func hasStatus(err error, s int) bool {
+	e, ok := cause(err).(*ErrorResponse)
+	return ok && e.StatusCode == s
+}
+
+//cause unwraps errors that carry the error they were caused by, like ApplyError and SecurityApplyError
+// so the Is functions also recognize the errors that are returned by the apply functions
+func cause(err error) error {
+	for {
+		c, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			return err
+		}
+		err = c.Cause()
+	}
+}
+
+//validationError turns an ErrorResponse carrying a ci annotated with validation-messages into a ValidationError
+// any other error is returned as is
+func validationError(err error) error {
+	e, ok := err.(*ErrorResponse)
+	if !ok {
+		return err
+	}
+
+	var c Ci
+	if json.Unmarshal([]byte(e.Message), &c) != nil || len(c.ValidationMessages) == 0 {
+		return err
+	}
+
+	return &ValidationError{CiID: c.ID, Messages: c.ValidationMessages}
+}
@@ -0,0 +1,135 @@
+package xld
+
+import (
+	"sync"
+	"time"
+)
+
+//DefaultMetaDataTTL is the time type descriptors stay cached when Config.MetaDataTTL is not set
+const DefaultMetaDataTTL = 10 * time.Minute
+
+//metaCache is a concurrency safe in-memory cache of type descriptors
+// types holds the names of the complete type system once it was cached as a whole
+type metaCache struct {
+	mu           sync.RWMutex
+	ttl          time.Duration
+	entries      map[string]metaCacheEntry
+	types        []string
+	typesExpires time.Time
+}
+
+type metaCacheEntry struct {
+	meta    MetaData
+	expires time.Time
+}
+
+//newMetaCache returns a cache that keeps entries for ttl
+// a zero ttl uses DefaultMetaDataTTL, a negative ttl disables caching
+func newMetaCache(ttl time.Duration) *metaCache {
+	if ttl == 0 {
+		ttl = DefaultMetaDataTTL
+	}
+
+	return &metaCache{ttl: ttl, entries: make(map[string]metaCacheEntry)}
+}
+
+func (c *metaCache) enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+func (c *metaCache) get(t string) (MetaData, bool) {
+	if !c.enabled() {
+		return MetaData{}, false
+	}
+
+	c.mu.RLock()
+	e, ok := c.entries[t]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(e.expires) {
+		return MetaData{}, false
+	}
+
+	return e.meta, true
+}
+
+func (c *metaCache) put(m ...MetaData) {
+	if !c.enabled() {
+		return
+	}
+
+	expires := time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	for _, d := range m {
+		c.entries[d.Type] = metaCacheEntry{meta: d, expires: expires}
+	}
+	c.mu.Unlock()
+}
+
+//putAll caches the complete type system so it can be served by all
+func (c *metaCache) putAll(m MetaDataList) {
+	if !c.enabled() {
+		return
+	}
+
+	c.put(m...)
+
+	types := make([]string, len(m))
+	for i, d := range m {
+		types[i] = d.Type
+	}
+
+	c.mu.Lock()
+	c.types = types
+	c.typesExpires = time.Now().Add(c.ttl)
+	c.mu.Unlock()
+}
+
+//all returns the complete type system when it is cached and none of its types expired or was invalidated
+func (c *metaCache) all() (MetaDataList, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.types == nil || time.Now().After(c.typesExpires) {
+		return nil, false
+	}
+
+	l := make(MetaDataList, len(c.types))
+	for i, t := range c.types {
+		e, ok := c.entries[t]
+		if !ok {
+			return nil, false
+		}
+		l[i] = e.meta
+	}
+
+	return l, true
+}
+
+func (c *metaCache) invalidate(t ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	for _, n := range t {
+		delete(c.entries, n)
+	}
+	c.mu.Unlock()
+}
+
+func (c *metaCache) invalidateAll() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries = make(map[string]metaCacheEntry)
+	c.types = nil
+	c.mu.Unlock()
+}
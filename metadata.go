@@ -1,17 +1,38 @@
 package xld
 
+import (
+	"context"
+)
+
 const (
 	MetaDataBasePath = "deployit/metadata"
 )
 
 type MetaDataService interface {
 	GetProperties(t string) (map[string]string, error)
+	GetPropertiesContext(ctx context.Context, t string) (map[string]string, error)
 	GetType(t string) (MetaData, error)
+	GetTypeContext(ctx context.Context, t string) (MetaData, error)
+	Preload() error
+	PreloadContext(ctx context.Context) error
+	Invalidate(t ...string)
+	InvalidateAll()
+	ListTypes() (MetaDataList, error)
+	ListTypesContext(ctx context.Context) (MetaDataList, error)
+	ListDescendants(superType string) (MetaDataList, error)
+	ListDescendantsContext(ctx context.Context, superType string) (MetaDataList, error)
+	IsSubtypeOf(t, superType string) (bool, error)
+	IsSubtypeOfContext(ctx context.Context, t, superType string) (bool, error)
+	DeployableTypesFor(containerType string) ([]string, error)
+	DeployableTypesForContext(ctx context.Context, containerType string) ([]string, error)
+	ControlTasksFor(t string) ([]ControlTask, error)
+	ControlTasksForContext(ctx context.Context, t string) ([]ControlTask, error)
 }
 
 //RepositoryServiceOp holds the communication service for Repositorys
 type MetaDataServiceOp struct {
 	client *Client
+	cache  *metaCache
 }
 
 var _ MetaDataService = &MetaDataServiceOp{}
@@ -48,6 +69,7 @@ type Property struct {
 	Size               string      `json:"size, omitempty"`
 	ReferencedType     string      `json:"referencedType, omitempty"`
 	Default            interface{} `json:"default, omitempty"`
+	EnumValues         []string    `json:"enumValues,omitempty"`
 }
 
 type ControlTask struct {
@@ -58,25 +80,72 @@ type ControlTask struct {
 }
 
 //GetType retrieve MetaData
+// descriptors are served from the cache while they are younger than Config.MetaDataTTL
 func (m MetaDataServiceOp) GetType(t string) (MetaData, error) {
+	return m.GetTypeContext(context.Background(), t)
+}
+
+//GetTypeContext is GetType with a context that is attached to every request it makes
+func (m MetaDataServiceOp) GetTypeContext(ctx context.Context, t string) (MetaData, error) {
+
+	if meta, ok := m.cache.get(t); ok {
+		return meta, nil
+	}
 
 	var meta MetaData
 
 	url := MetaDataBasePath + "/" + "type" + "/" + t
 
-	req, err := m.client.NewRequest(url, "GET", nil)
+	req, err := m.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return meta, err
+	}
 
 	_, err = m.client.Do(req, &meta)
+	if err != nil {
+		return meta, err
+	}
+
+	m.cache.put(meta)
 
-	return meta, err
+	return meta, nil
 
 }
 
+//Preload fetches the descriptors of the complete type system in one request and caches them
+// calling it right after NewClient saves a metadata request for every type that is used later on,
+// ListDescendants and DeployableTypesFor are answered from the cache as well
+func (m MetaDataServiceOp) Preload() error {
+	return m.PreloadContext(context.Background())
+}
+
+//PreloadContext is Preload with a context that is attached to every request it makes
+func (m MetaDataServiceOp) PreloadContext(ctx context.Context) error {
+	_, err := m.ListTypesContext(ctx)
+	return err
+}
+
+//Invalidate removes the given types from the metadata cache
+func (m MetaDataServiceOp) Invalidate(t ...string) {
+	m.cache.invalidate(t...)
+}
+
+//InvalidateAll empties the metadata cache
+// use it after installing plugins that change the type system
+func (m MetaDataServiceOp) InvalidateAll() {
+	m.cache.invalidateAll()
+}
+
 func (m MetaDataServiceOp) GetProperties(t string) (map[string]string, error) {
+	return m.GetPropertiesContext(context.Background(), t)
+}
+
+//GetPropertiesContext is GetProperties with a context that is attached to every request it makes
+func (m MetaDataServiceOp) GetPropertiesContext(ctx context.Context, t string) (map[string]string, error) {
 
 	p := make(map[string]string)
 
-	d, err := m.GetType(t)
+	d, err := m.GetTypeContext(ctx, t)
 	if err != nil {
 		return p, err
 	}
@@ -0,0 +1,192 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGetTypeCached(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := 0
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		calls++
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Meta.GetProperties("overthere.SshHost"); err != nil {
+			t.Fatalf("GetProperties returned error: %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 metadata request but got %v", calls)
+	}
+
+	client.Meta.Invalidate("overthere.SshHost")
+
+	if _, err := client.Meta.GetType("overthere.SshHost"); err != nil {
+		t.Fatalf("GetType returned error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected a new metadata request after Invalidate but got %v requests", calls)
+	}
+}
+
+func TestGetTypeCacheDisabled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Meta = &MetaDataServiceOp{client: client, cache: newMetaCache(-1)}
+
+	calls := 0
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+
+	client.Meta.GetType("overthere.SshHost")
+	client.Meta.GetType("overthere.SshHost")
+
+	if calls != 2 {
+		t.Errorf("Expected 2 metadata requests with the cache disabled but got %v", calls)
+	}
+}
+
+func TestPreload(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, "["+mockTestSshHostMetaResponse+"]")
+	})
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected overthere.SshHost to be served from the cache")
+	})
+
+	if err := client.Meta.Preload(); err != nil {
+		t.Fatalf("Preload returned error: %v", err)
+	}
+
+	m, err := client.Meta.GetType("overthere.SshHost")
+	if err != nil {
+		t.Fatalf("GetType returned error: %v", err)
+	}
+
+	if m.Type != "overthere.SshHost" {
+		t.Errorf("Expected overthere.SshHost but got %v", m.Type)
+	}
+}
+
+func TestTypeHierarchy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	fetched := 0
+	mux.HandleFunc("/deployit/metadata/type", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fetched++
+		fmt.Fprint(w, mockTestTypeSystemResponse)
+	})
+
+	d, err := client.Meta.ListDescendants("udm.Container")
+	if err != nil {
+		t.Fatalf("ListDescendants returned error: %v", err)
+	}
+
+	var names []string
+	for _, m := range d {
+		names = append(names, m.Type)
+	}
+
+	if !reflect.DeepEqual(names, []string{"overthere.Host", "overthere.SshHost"}) {
+		t.Errorf("ListDescendants returned %v", names)
+	}
+
+	ok, err := client.Meta.IsSubtypeOf("overthere.SshHost", "udm.Container")
+	if err != nil || !ok {
+		t.Errorf("Expected overthere.SshHost to be a udm.Container, got %v %v", ok, err)
+	}
+
+	ok, err = client.Meta.IsSubtypeOf("udm.Container", "overthere.SshHost")
+	if err != nil || ok {
+		t.Errorf("Expected udm.Container not to be an overthere.SshHost, got %v %v", ok, err)
+	}
+
+	types, err := client.Meta.DeployableTypesFor("overthere.SshHost")
+	if err != nil {
+		t.Fatalf("DeployableTypesFor returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(types, []string{"cmd.Command", "file.File"}) {
+		t.Errorf("DeployableTypesFor returned %v", types)
+	}
+
+	if fetched != 1 {
+		t.Errorf("Expected the type system to be fetched once but it was fetched %d times", fetched)
+	}
+
+	client.Meta.Invalidate("overthere.Host")
+	if _, err := client.Meta.ListDescendants("udm.Container"); err != nil || fetched != 2 {
+		t.Errorf("Expected the type system to be fetched again after an invalidate, got %d fetches and %v", fetched, err)
+	}
+
+	tasks, err := client.Meta.ControlTasksFor("overthere.SshHost")
+	if err != nil {
+		t.Fatalf("ControlTasksFor returned error: %v", err)
+	}
+
+	if len(tasks) != 1 || tasks[0].Name != "checkConnection" {
+		t.Errorf("ControlTasksFor returned %v", tasks)
+	}
+}
+
+func TestIsSubtypeOfCached(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTypeSystemResponse)
+	})
+
+	hits := 0
+	mux.HandleFunc("/deployit/metadata/type/", func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.NotFound(w, r)
+	})
+
+	if _, err := client.Meta.ListDescendants("udm.Container"); err != nil {
+		t.Fatalf("ListDescendants returned error: %v", err)
+	}
+
+	ok, err := client.Meta.IsSubtypeOf("overthere.SshHost", "udm.Container")
+	if err != nil || !ok {
+		t.Errorf("Expected overthere.SshHost to be a udm.Container, got %v %v", ok, err)
+	}
+
+	ok, err = client.Meta.IsSubtypeOf("file.DeployedFile", "udm.Container")
+	if err != nil || ok {
+		t.Errorf("Expected file.DeployedFile not to be a udm.Container, got %v %v", ok, err)
+	}
+
+	if hits != 0 {
+		t.Errorf("Expected IsSubtypeOf to be answered from the cached type system but it sent %d requests", hits)
+	}
+}
+
+var mockTestTypeSystemResponse = `[
+  {"type": "udm.Container", "virtual": true},
+  {"type": "overthere.Host", "virtual": true, "interfaces": ["udm.Container"]},
+  {"type": "overthere.SshHost", "superTypes": ["overthere.Host"],
+   "control-tasks": [{"name": "checkConnection", "label": "Check connection"}]},
+  {"type": "file.DeployedFile", "deployableType": "file.File", "containerType": "overthere.Host"},
+  {"type": "cmd.DeployedCommand", "deployableType": "cmd.Command", "containerType": "overthere.Host"},
+  {"type": "www.DeployedWebContent", "deployableType": "www.WebContent", "containerType": "www.ApacheHttpdServer"}
+]`
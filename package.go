@@ -0,0 +1,138 @@
+package xld
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const (
+	packageBasePath = "deployit/package"
+)
+
+//PackageService represents the service for importing deployment packages into xldeploy
+type PackageService interface {
+	ListImportable() ([]string, error)
+	ListImportableContext(ctx context.Context) ([]string, error)
+	Import(n string) (Ci, error)
+	ImportContext(ctx context.Context, n string) (Ci, error)
+	Fetch(u string) (Ci, error)
+	FetchContext(ctx context.Context, u string) (Ci, error)
+	Upload(n string, r io.Reader) (Ci, error)
+	UploadContext(ctx context.Context, n string, r io.Reader) (Ci, error)
+	UploadFile(path string) (Ci, error)
+	UploadFileContext(ctx context.Context, path string) (Ci, error)
+}
+
+//PackageServiceOp holds the communication service for the package rest api
+type PackageServiceOp struct {
+	client *Client
+}
+
+var _ PackageService = &PackageServiceOp{}
+
+//ListImportable returns the packages in the importablePackages directory of the xldeploy server
+func (p PackageServiceOp) ListImportable() ([]string, error) {
+	return p.ListImportableContext(context.Background())
+}
+
+//ListImportableContext is ListImportable with a context that is attached to every request it makes
+func (p PackageServiceOp) ListImportableContext(ctx context.Context) ([]string, error) {
+	var l []string
+
+	url := packageBasePath + "/import"
+
+	req, err := p.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return l, err
+	}
+
+	_, err = p.client.Do(req, &l)
+
+	return l, err
+}
+
+//Import imports a package from the importablePackages directory of the xldeploy server
+// n is the name as returned by ListImportable, the created udm.DeploymentPackage is returned
+func (p PackageServiceOp) Import(n string) (Ci, error) {
+	return p.ImportContext(context.Background(), n)
+}
+
+//ImportContext is Import with a context that is attached to every request it makes
+func (p PackageServiceOp) ImportContext(ctx context.Context, n string) (Ci, error) {
+	var c Ci
+
+	req, err := p.client.NewRequestContext(ctx, packageBasePath+"/import/"+escapePath(n), "POST", nil)
+	if err != nil {
+		return c, err
+	}
+
+	_, err = p.client.Do(req, &c)
+
+	return c, err
+}
+
+//Fetch lets xldeploy download a package from u and import it
+// the created udm.DeploymentPackage is returned
+func (p PackageServiceOp) Fetch(u string) (Ci, error) {
+	return p.FetchContext(context.Background(), u)
+}
+
+//FetchContext is Fetch with a context that is attached to every request it makes
+func (p PackageServiceOp) FetchContext(ctx context.Context, u string) (Ci, error) {
+	var c Ci
+
+	req, err := p.client.NewRequestContext(ctx, packageBasePath+"/fetch", "POST", u)
+	if err != nil {
+		return c, err
+	}
+
+	_, err = p.client.Do(req, &c)
+
+	return c, err
+}
+
+//Upload sends the dar read from r to xldeploy as file n and imports it
+// the dar is streamed, the created udm.DeploymentPackage is returned
+func (p PackageServiceOp) Upload(n string, r io.Reader) (Ci, error) {
+	return p.UploadContext(context.Background(), n, r)
+}
+
+//UploadContext is Upload with a context that is attached to every request it makes
+func (p PackageServiceOp) UploadContext(ctx context.Context, n string, r io.Reader) (Ci, error) {
+	var c Ci
+
+	req, err := p.client.NewMultipartRequestContext(ctx, packageBasePath+"/upload/"+escapePath(n), "fileData", n, r)
+	if err != nil {
+		return c, err
+	}
+
+	_, err = p.client.Do(req, &c)
+
+	return c, err
+}
+
+//UploadFile uploads the dar at path, the file name is used as package name
+func (p PackageServiceOp) UploadFile(path string) (Ci, error) {
+	return p.UploadFileContext(context.Background(), path)
+}
+
+//UploadFileContext is UploadFile with a context that is attached to every request it makes
+func (p PackageServiceOp) UploadFileContext(ctx context.Context, path string) (Ci, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Ci{}, err
+	}
+	defer f.Close()
+
+	return p.UploadContext(ctx, filepath.Base(path), f)
+}
+
+//private functions
+
+//escapePath escapes a package name for use in a url path, the / of packages in subdirectories are kept
+func escapePath(n string) string {
+	return (&url.URL{Path: n}).EscapedPath()
+}
@@ -0,0 +1,130 @@
+package xld
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestListImportable(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/package/import", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `["PetClinic-ear/1.0", "PetClinic-ear/2.0"]`)
+	})
+
+	l, err := client.Package.ListImportable()
+	if err != nil {
+		t.Fatalf("ListImportable returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(l, []string{"PetClinic-ear/1.0", "PetClinic-ear/2.0"}) {
+		t.Errorf("ListImportable returned %v", l)
+	}
+}
+
+func TestImportPackage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/package/import/PetClinic-ear/1.0", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprint(w, mockTestPackageResponse)
+	})
+
+	c, err := client.Package.Import("PetClinic-ear/1.0")
+	if err != nil {
+		t.Fatalf("Import returned error: %v", err)
+	}
+
+	if c.ID != "Applications/PetClinic-ear/1.0" || c.Type != "udm.DeploymentPackage" {
+		t.Errorf("Import returned %+v", c)
+	}
+}
+
+func TestFetchPackage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/package/fetch", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var u string
+		json.NewDecoder(r.Body).Decode(&u)
+		if u != "http://repo/PetClinic-1.0.dar" {
+			t.Errorf("Expected the url to be sent but got %v", u)
+		}
+
+		fmt.Fprint(w, mockTestPackageResponse)
+	})
+
+	if _, err := client.Package.Fetch("http://repo/PetClinic-1.0.dar"); err != nil {
+		t.Errorf("Fetch returned error: %v", err)
+	}
+}
+
+func TestUploadPackage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/package/upload/PetClinic-1.0.dar", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		f, h, err := r.FormFile("fileData")
+		if err != nil {
+			t.Fatalf("Expected a multipart upload but got %v", err)
+		}
+
+		b, _ := ioutil.ReadAll(f)
+		if h.Filename != "PetClinic-1.0.dar" || string(b) != "dar content" {
+			t.Errorf("Unexpected upload %v: %s", h.Filename, b)
+		}
+
+		fmt.Fprint(w, mockTestPackageResponse)
+	})
+
+	c, err := client.Package.Upload("PetClinic-1.0.dar", strings.NewReader("dar content"))
+	if err != nil {
+		t.Fatalf("Upload returned error: %v", err)
+	}
+
+	if c.ID != "Applications/PetClinic-ear/1.0" {
+		t.Errorf("Upload returned %+v", c)
+	}
+}
+
+func TestMultipartRequestNotSent(t *testing.T) {
+	c := NewClient(&mockConfig)
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		req, err := c.NewMultipartRequestContext(context.Background(), "deployit/package/upload/test.dar", "fileData", "test.dar", strings.NewReader("dar content"))
+		if err != nil {
+			t.Fatalf("NewMultipartRequestContext returned error: %v", err)
+		}
+		if req.Header.Get("Content-Type") == "" {
+			t.Errorf("Expected a multipart content type")
+		}
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected requests that are never sent to start no goroutines, %d before and %d after", before, after)
+	}
+}
+
+var mockTestPackageResponse = `{
+  "id": "Applications/PetClinic-ear/1.0",
+  "type": "udm.DeploymentPackage",
+  "$token": "a6b4b0de-9b5b-4f2b-a5f0-2a7b0c1d2e3f",
+  "application": "Applications/PetClinic-ear",
+  "deployables": ["Applications/PetClinic-ear/1.0/PetClinic"]
+}`
@@ -0,0 +1,132 @@
+package xld
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+//Permission is a permission granted to a role
+// CiID is empty for global permissions like login or security#edit
+type Permission struct {
+	Role       string `json:"role"`
+	Permission string `json:"permission"`
+	CiID       string `json:"ci,omitempty"`
+}
+
+//ListPermissions returns every permission granted to role, global ones as well as the ones on cis
+// the result is sorted by ci id and permission name
+func (s SecurityServiceOp) ListPermissions(role string) ([]Permission, error) {
+	return s.ListPermissionsContext(context.Background(), role)
+}
+
+//ListPermissionsContext is ListPermissions with a context that is attached to every request it makes
+func (s SecurityServiceOp) ListPermissionsContext(ctx context.Context, role string) ([]Permission, error) {
+	var granted map[string][]string
+
+	url := securityBasePath + "/granted-permissions/role/" + role
+
+	req, err := s.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.Do(req, &granted)
+	if err != nil {
+		return nil, err
+	}
+
+	// xldeploy reports the global permissions under an empty key
+	var ids []string
+	for id := range granted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var p []Permission
+	for _, id := range ids {
+		names := granted[id]
+		sort.Strings(names)
+
+		for _, n := range names {
+			p = append(p, Permission{Role: role, Permission: n, CiID: id})
+		}
+	}
+
+	return p, nil
+}
+
+//GetPermissions returns the permissions role has on the ci with id ciID
+// use an empty ciID for the global permissions of the role
+func (s SecurityServiceOp) GetPermissions(role, ciID string) ([]Permission, error) {
+	return s.GetPermissionsContext(context.Background(), role, ciID)
+}
+
+//GetPermissionsContext is GetPermissions with a context that is attached to every request it makes
+func (s SecurityServiceOp) GetPermissionsContext(ctx context.Context, role, ciID string) ([]Permission, error) {
+	all, err := s.ListPermissionsContext(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+
+	p := []Permission{}
+	for _, e := range all {
+		if e.CiID == ciID {
+			p = append(p, e)
+		}
+	}
+
+	return p, nil
+}
+
+//GrantPermission grants p.Permission on p.CiID to p.Role
+func (s SecurityServiceOp) GrantPermission(p Permission) error {
+	return s.GrantPermissionContext(context.Background(), p)
+}
+
+//GrantPermissionContext is GrantPermission with a context that is attached to every request it makes
+func (s SecurityServiceOp) GrantPermissionContext(ctx context.Context, p Permission) error {
+	return s.roleAction(ctx, "PUT", p.path())
+}
+
+//RevokePermission revokes p.Permission on p.CiID from p.Role
+func (s SecurityServiceOp) RevokePermission(p Permission) error {
+	return s.RevokePermissionContext(context.Background(), p)
+}
+
+//RevokePermissionContext is RevokePermission with a context that is attached to every request it makes
+func (s SecurityServiceOp) RevokePermissionContext(ctx context.Context, p Permission) error {
+	return s.roleAction(ctx, "DELETE", p.path())
+}
+
+//CheckPermission reports whether the user the client is logged in with has permission on the ci with id ciID
+func (s SecurityServiceOp) CheckPermission(permission, ciID string) (bool, error) {
+	return s.CheckPermissionContext(context.Background(), permission, ciID)
+}
+
+//CheckPermissionContext is CheckPermission with a context that is attached to every request it makes
+func (s SecurityServiceOp) CheckPermissionContext(ctx context.Context, permission, ciID string) (bool, error) {
+	var granted bool
+
+	url := securityBasePath + "/check/" + escapePermission(permission) + "/" + ciID
+
+	req, err := s.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = s.client.Do(req, &granted)
+
+	return granted, err
+}
+
+//private functions
+
+func (p Permission) path() string {
+	return "/permission/" + escapePermission(p.Permission) + "/" + p.Role + "/" + p.CiID
+}
+
+//escapePermission escapes the # in permission names like repo#edit so it does not end up as url fragment
+func escapePermission(p string) string {
+	return strings.Replace(p, "#", "%23", -1)
+}
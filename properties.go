@@ -0,0 +1,299 @@
+package xld
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Property kinds as reported by the xldeploy metadata
+const (
+	KindBoolean         = "BOOLEAN"
+	KindInteger         = "INTEGER"
+	KindString          = "STRING"
+	KindEnum            = "ENUM"
+	KindDate            = "DATE"
+	KindCi              = "CI"
+	KindSetOfString     = "SET_OF_STRING"
+	KindSetOfCi         = "SET_OF_CI"
+	KindListOfString    = "LIST_OF_STRING"
+	KindListOfCi        = "LIST_OF_CI"
+	KindMapStringString = "MAP_STRING_STRING"
+)
+
+//the range of an int on the platform the package is built for, untyped so they compare with any number
+const (
+	intSize = 32 << (^uint(0) >> 63)
+	maxInt  = 1<<(intSize-1) - 1
+	minInt  = -1 << (intSize - 1)
+)
+
+//PropertyError describes a single property that could not be converted for its type
+type PropertyError struct {
+	Name   string
+	Kind   string
+	Reason string
+}
+
+//PropertiesError lists all properties of a ci that could not be converted
+type PropertiesError struct {
+	CiID   string
+	Type   string
+	Errors []PropertyError
+}
+
+func (e *PropertiesError) Error() string {
+	m := make([]string, len(e.Errors))
+
+	for i, p := range e.Errors {
+		m[i] = p.Name + ": " + p.Reason
+	}
+
+	return fmt.Sprintf("invalid properties for ci %s of type %s: %s", e.CiID, e.Type, strings.Join(m, "; "))
+}
+
+//ConvertProperty converts v to the json representation xldeploy expects for a property of kind
+// it is the conversion NewCi and TranslateCiProperties apply to every property
+func ConvertProperty(kind string, v interface{}) (interface{}, error) {
+	return convertProperty(kind, v)
+}
+
+//private functions
+
+//convertProperties converts every property to the json representation xldeploy expects for its kind
+// properties with an empty value are left out
+func convertProperties(n, t string, p map[string]interface{}, metaData map[string]string) (map[string]interface{}, error) {
+	c := make(map[string]interface{})
+	e := &PropertiesError{CiID: n, Type: t}
+
+	for k, v := range p {
+		kind, ok := metaData[k]
+		if !ok {
+			e.Errors = append(e.Errors, PropertyError{Name: k, Reason: "unknown property for type " + t})
+			continue
+		}
+
+		if v == nil {
+			continue
+		}
+
+		cv, err := convertProperty(kind, v)
+		if err != nil {
+			e.Errors = append(e.Errors, PropertyError{Name: k, Kind: kind, Reason: err.Error()})
+			continue
+		}
+
+		if !isEmptyProperty(cv) {
+			c[k] = cv
+		}
+	}
+
+	if len(e.Errors) > 0 {
+		return c, e
+	}
+
+	return c, nil
+}
+
+//convertProperty converts a single value to the json representation xldeploy expects for kind
+func convertProperty(kind string, v interface{}) (interface{}, error) {
+	switch kind {
+	case KindString, KindEnum:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case KindBoolean:
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	case KindInteger:
+		if i, ok := toInt(v); ok {
+			return i, nil
+		}
+	case KindDate:
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(queryDateFormat), nil
+		case string:
+			return v, nil
+		}
+	case KindCi:
+		if id, ok := toCiRef(v); ok {
+			return id, nil
+		}
+	case KindSetOfString, KindListOfString:
+		if l, ok := toList(v, toString); ok {
+			return l, nil
+		}
+	case KindSetOfCi, KindListOfCi:
+		if l, ok := toCiRefs(v); ok {
+			return l, nil
+		}
+	case KindMapStringString:
+		if m, ok := toStringMap(v); ok {
+			return m, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported property kind %s", kind)
+	}
+
+	return nil, fmt.Errorf("can not use %T as %s", v, kind)
+}
+
+func isEmptyProperty(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+func toString(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		if v >= minInt && v <= maxInt {
+			return int(v), true
+		}
+	case uint:
+		return toInt(uint64(v))
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return toInt(uint64(v))
+	case uint64:
+		if v <= maxInt {
+			return int(v), true
+		}
+	case float32:
+		return toInt(float64(v))
+	case float64:
+		// maxInt itself is not exact as a float, maxInt+1 is
+		if v == math.Trunc(v) && v >= minInt && v < maxInt+1 {
+			return int(v), true
+		}
+	case json.Number:
+		return toInt(string(v))
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+//toCiRef turns a reference to a ci into its id
+// a reference can be an id, a Ci, a CiListEntry or an {"id": .., "type": ..} object
+func toCiRef(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case Ci:
+		return v.ID, v.ID != ""
+	case *Ci:
+		return v.ID, v != nil && v.ID != ""
+	case CiListEntry:
+		return v.ID, v.ID != ""
+	case map[string]interface{}:
+		id, ok := v["id"].(string)
+		return id, ok && id != ""
+	case map[string]string:
+		id, ok := v["id"]
+		return id, ok && id != ""
+	}
+	return "", false
+}
+
+func toCiRefs(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case []Ci:
+		l := make([]string, len(v))
+		for i, c := range v {
+			l[i] = c.ID
+		}
+		return l, true
+	case CiList:
+		return toCiRefs([]CiListEntry(v))
+	case []CiListEntry:
+		l := make([]string, len(v))
+		for i, c := range v {
+			l[i] = c.ID
+		}
+		return l, true
+	}
+	return toList(v, toCiRef)
+}
+
+//toList converts []string and []interface{} values using convert for every element
+func toList(v interface{}, convert func(interface{}) (string, bool)) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		l := make([]string, len(v))
+		for i, e := range v {
+			s, ok := convert(e)
+			if !ok {
+				return nil, false
+			}
+			l[i] = s
+		}
+		return l, true
+	case []interface{}:
+		l := make([]string, len(v))
+		for i, e := range v {
+			s, ok := convert(e)
+			if !ok {
+				return nil, false
+			}
+			l[i] = s
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+func toStringMap(v interface{}) (map[string]string, bool) {
+	switch v := v.(type) {
+	case map[string]string:
+		return v, true
+	case map[string]interface{}:
+		m := make(map[string]string, len(v))
+		for k, e := range v {
+			switch e := e.(type) {
+			case string:
+				m[k] = e
+			case float64:
+				m[k] = strconv.FormatFloat(e, 'f', -1, 64)
+			case bool, int, int64, json.Number:
+				m[k] = fmt.Sprint(e)
+			default:
+				return nil, false
+			}
+		}
+		return m, true
+	}
+	return nil, false
+}
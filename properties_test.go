@@ -0,0 +1,95 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertProperty(t *testing.T) {
+
+	cases := []struct {
+		kind     string
+		value    interface{}
+		expected interface{}
+		err      bool
+	}{
+		{kind: KindString, value: "test", expected: "test"},
+		{kind: KindString, value: 12, err: true},
+		{kind: KindBoolean, value: "true", expected: true},
+		{kind: KindBoolean, value: "yes please", err: true},
+		{kind: KindInteger, value: float64(22), expected: 22},
+		{kind: KindInteger, value: "22", expected: 22},
+		{kind: KindInteger, value: 22.5, err: true},
+		{kind: KindInteger, value: uint64(1 << 63), err: true},
+		{kind: KindInteger, value: uint64(1<<31 - 1), expected: 1<<31 - 1},
+		{kind: KindInteger, value: 1e20, err: true},
+		{kind: KindInteger, value: -1e20, err: true},
+		{kind: KindInteger, value: float64(-1 << 31), expected: -1 << 31},
+		{kind: KindDate, value: time.Date(2016, 10, 4, 12, 0, 0, 0, time.UTC), expected: "2016-10-04T12:00:00.000+0000"},
+		{kind: KindCi, value: map[string]interface{}{"id": "Infrastructure/testHost", "type": "overthere.SshHost"}, expected: "Infrastructure/testHost"},
+		{kind: KindCi, value: Ci{ID: "Infrastructure/testHost"}, expected: "Infrastructure/testHost"},
+		{kind: KindListOfString, value: []interface{}{"a", "b"}, expected: []string{"a", "b"}},
+		{kind: KindSetOfString, value: []interface{}{"a", 1}, err: true},
+		{kind: KindSetOfCi, value: []interface{}{"Infrastructure/a", map[string]interface{}{"id": "Infrastructure/b"}}, expected: []string{"Infrastructure/a", "Infrastructure/b"}},
+		{kind: KindListOfCi, value: CiList{{ID: "Infrastructure/a"}}, expected: []string{"Infrastructure/a"}},
+		{kind: KindMapStringString, value: map[string]interface{}{"a": "b", "port": float64(8080)}, expected: map[string]string{"a": "b", "port": "8080"}},
+		{kind: "BOGUS", value: "test", err: true},
+	}
+
+	for _, c := range cases {
+		v, err := convertProperty(c.kind, c.value)
+		if c.err {
+			if err == nil {
+				t.Errorf("Expected an error converting %v to %v but got %v", c.value, c.kind, v)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Converting %v to %v returned error: %v", c.value, c.kind, err)
+		}
+
+		if !reflect.DeepEqual(v, c.expected) {
+			t.Errorf("Converting %v to %v returned %#v, expected %#v", c.value, c.kind, v, c.expected)
+		}
+	}
+}
+
+func TestNewCiPropertiesError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+
+	ci, err := client.Repository.NewCi("Infrastructure/testHost", "overthere.SshHost", map[string]interface{}{
+		"address": "localhost",
+		"port":    float64(22),
+		"tags":    []interface{}{"web"},
+		"bogus":   "value",
+		"os":      true,
+	})
+
+	e, ok := err.(*PropertiesError)
+	if !ok {
+		t.Fatalf("Expected a *PropertiesError but got %v", err)
+	}
+
+	failed := make(map[string]bool)
+	for _, p := range e.Errors {
+		failed[p.Name] = true
+	}
+
+	if len(failed) != 2 || !failed["bogus"] || !failed["os"] {
+		t.Errorf("Expected bogus and os to fail but got %v", e)
+	}
+
+	expected := map[string]interface{}{"address": "localhost", "port": 22, "tags": []string{"web"}}
+	if !reflect.DeepEqual(ci.Properties, expected) {
+		t.Errorf("Expected the valid properties to be converted to %v but got %v", expected, ci.Properties)
+	}
+}
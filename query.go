@@ -0,0 +1,164 @@
+package xld
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//queryDateFormat is the date format xldeploy uses in query parameters
+	queryDateFormat = "2006-01-02T15:04:05.000-0700"
+
+	defaultResultsPerPage = 100
+)
+
+//QueryOptions holds the filters for a repository query
+// zero values are left out of the query, Ancestor is a ci id and is sent without a leading slash
+type QueryOptions struct {
+	Type               string
+	Parent             string
+	Ancestor           string
+	NamePattern        string
+	LastModifiedBefore time.Time
+	LastModifiedAfter  time.Time
+	Page               int
+	ResultsPerPage     int
+	// Properties asks xldeploy to include the ci metadata ($lastModifiedAt, ...) in the results
+	Properties bool
+}
+
+//CiIterator walks through all pages of a repository query
+type CiIterator struct {
+	r     RepositoryServiceOp
+	ctx   context.Context
+	opts  QueryOptions
+	page  CiList
+	i     int
+	done  bool
+	entry CiListEntry
+	err   error
+}
+
+//Query retrieves the CIs matching the given options
+func (r RepositoryServiceOp) Query(o QueryOptions) (CiList, error) {
+	return r.QueryContext(context.Background(), o)
+}
+
+//QueryContext is Query with a context that is attached to every request it makes
+func (r RepositoryServiceOp) QueryContext(ctx context.Context, o QueryOptions) (CiList, error) {
+	var ciList CiList
+
+	url := repositoryBasePath + "/query"
+	if q := o.values().Encode(); q != "" {
+		url = url + "?" + q
+	}
+
+	req, err := r.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return ciList, err
+	}
+
+	_, err = r.client.Do(req, &ciList)
+
+	return ciList, err
+}
+
+//QueryAll returns an iterator that transparently walks all pages of a query
+// the iteration starts at o.Page and uses o.ResultsPerPage (100 when not set) as page size
+func (r RepositoryServiceOp) QueryAll(o QueryOptions) *CiIterator {
+	return r.QueryAllContext(context.Background(), o)
+}
+
+//QueryAllContext is QueryAll with a context that is attached to every request it makes
+func (r RepositoryServiceOp) QueryAllContext(ctx context.Context, o QueryOptions) *CiIterator {
+	if o.ResultsPerPage <= 0 {
+		o.ResultsPerPage = defaultResultsPerPage
+	}
+
+	return &CiIterator{r: r, ctx: ctx, opts: o}
+}
+
+//Next advances the iterator to the next entry, fetching the next page when needed
+// it returns false when there are no more entries or an error occurred
+func (it *CiIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.i >= len(it.page) {
+		if it.done {
+			return false
+		}
+
+		it.page, it.err = it.r.QueryContext(it.ctx, it.opts)
+		if it.err != nil {
+			return false
+		}
+
+		it.i = 0
+		it.opts.Page++
+
+		// a short page is the last one
+		if len(it.page) < it.opts.ResultsPerPage {
+			it.done = true
+		}
+
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.entry = it.page[it.i]
+	it.i++
+
+	return true
+}
+
+//Entry returns the entry the iterator is currently at
+func (it *CiIterator) Entry() CiListEntry {
+	return it.entry
+}
+
+//Err returns the error that stopped the iteration, if any
+func (it *CiIterator) Err() error {
+	return it.err
+}
+
+//private functions
+
+func (o QueryOptions) values() url.Values {
+	q := url.Values{}
+
+	if o.Type != "" {
+		q.Set("type", o.Type)
+	}
+	if o.Parent != "" {
+		q.Set("parent", o.Parent)
+	}
+	if o.Ancestor != "" {
+		q.Set("ancestor", strings.TrimPrefix(o.Ancestor, "/"))
+	}
+	if o.NamePattern != "" {
+		q.Set("namePattern", o.NamePattern)
+	}
+	if !o.LastModifiedBefore.IsZero() {
+		q.Set("lastModifiedBefore", o.LastModifiedBefore.Format(queryDateFormat))
+	}
+	if !o.LastModifiedAfter.IsZero() {
+		q.Set("lastModifiedAfter", o.LastModifiedAfter.Format(queryDateFormat))
+	}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.ResultsPerPage > 0 {
+		q.Set("resultsPerPage", strconv.Itoa(o.ResultsPerPage))
+	}
+	if o.Properties {
+		q.Set("properties", "true")
+	}
+
+	return q
+}
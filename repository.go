@@ -2,9 +2,10 @@ package xld
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
 	"path"
 	"strings"
 )
@@ -12,6 +13,7 @@ import (
 const (
 	repositoryBasePath  = "deployit/repository"
 	environmentCiPrefix = "Environments"
+	maxConflictRetries  = 5
 )
 
 //RepositoryService is an interface representing the repository service
@@ -19,12 +21,55 @@ type RepositoryService interface {
 	//GetDictionary(n string) (DictionaryCI, error)
 	//GetGeneric(n string)
 	SaveCi(c Ci) (Ci, error)
+	SaveCiContext(ctx context.Context, c Ci) (Ci, error)
 	CreateCi(n string, t string, p map[string]interface{}) (Ci, error)
+	CreateCiContext(ctx context.Context, n string, t string, p map[string]interface{}) (Ci, error)
 	NewCi(n string, t string, p map[string]interface{}) (Ci, error)
+	NewCiContext(ctx context.Context, n string, t string, p map[string]interface{}) (Ci, error)
 	GetCi(n string) (Ci, error)
+	GetCiContext(ctx context.Context, n string) (Ci, error)
 	CiExists(n string) (bool, error)
+	CiExistsContext(ctx context.Context, n string) (bool, error)
 	ListCis(n string) (CiList, error)
+	ListCisContext(ctx context.Context, n string) (CiList, error)
 	TranslateCiProperties(n, t string, p map[string]interface{}) (map[string]interface{}, error)
+	TranslateCiPropertiesContext(ctx context.Context, n, t string, p map[string]interface{}) (map[string]interface{}, error)
+	DeleteCi(n string) error
+	DeleteCiContext(ctx context.Context, n string) error
+	DeleteCis(n ...string) error
+	DeleteCisContext(ctx context.Context, n ...string) error
+	MoveCi(from, to string) (Ci, error)
+	MoveCiContext(ctx context.Context, from, to string) (Ci, error)
+	RenameCi(n, name string) (Ci, error)
+	RenameCiContext(ctx context.Context, n, name string) (Ci, error)
+	CopyCi(from, to string) (Ci, error)
+	CopyCiContext(ctx context.Context, from, to string) (Ci, error)
+	CreateCis(c []Ci) ([]Ci, error)
+	CreateCisContext(ctx context.Context, c []Ci) ([]Ci, error)
+	UpdateCis(c []Ci) ([]Ci, error)
+	UpdateCisContext(ctx context.Context, c []Ci) ([]Ci, error)
+	GetCis(n ...string) ([]Ci, error)
+	GetCisContext(ctx context.Context, n ...string) ([]Ci, error)
+	Query(o QueryOptions) (CiList, error)
+	QueryContext(ctx context.Context, o QueryOptions) (CiList, error)
+	QueryAll(o QueryOptions) *CiIterator
+	QueryAllContext(ctx context.Context, o QueryOptions) *CiIterator
+	UpdateCiWithRetry(n string, mutate func(*Ci) error) (Ci, error)
+	UpdateCiWithRetryContext(ctx context.Context, n string, mutate func(*Ci) error) (Ci, error)
+	ValidateCi(c Ci) error
+	ValidateCiContext(ctx context.Context, c Ci) error
+	PlanApply(desired []Ci, o ApplyOptions) (ApplyPlan, error)
+	PlanApplyContext(ctx context.Context, desired []Ci, o ApplyOptions) (ApplyPlan, error)
+	Apply(p ApplyPlan) error
+	ApplyContext(ctx context.Context, p ApplyPlan) error
+	ExportTree(root string, o ExportOptions) (Snapshot, error)
+	ExportTreeContext(ctx context.Context, root string, o ExportOptions) (Snapshot, error)
+	ImportTree(s Snapshot, o ImportOptions) ([]Ci, error)
+	ImportTreeContext(ctx context.Context, s Snapshot, o ImportOptions) ([]Ci, error)
+	DiffCi(left, right string) (TreeDiff, error)
+	DiffCiContext(ctx context.Context, left, right string) (TreeDiff, error)
+	DiffTree(left, right string) (TreeDiff, error)
+	DiffTreeContext(ctx context.Context, left, right string) (TreeDiff, error)
 }
 
 //RepositoryServiceOp holds the communication service for Repositorys
@@ -44,12 +89,20 @@ type Ci struct {
 	LastModifiedBy string `json:"$lastModifiedBy,omitempty"`
 	LastModifiedAt string `json:"$lastModifiedAt,omitempty"`
 	Properties     map[string]interface{}
+	// ValidationMessages are only filled when xldeploy rejected the ci
+	ValidationMessages []ValidationMessage `json:"validation-messages,omitempty"`
 }
 
 //CiListEntry representation of a xldeploy query entry
+// the $ fields are only filled when they are requested with QueryOptions.Properties
 type CiListEntry struct {
-	ID   string `json:"ref"`
-	Type string `json:"type"`
+	ID             string `json:"ref"`
+	Type           string `json:"type"`
+	Token          string `json:"$token,omitempty"`
+	CreatedBy      string `json:"$createdBy,omitempty"`
+	CreatedAt      string `json:"$createdAt,omitempty"`
+	LastModifiedBy string `json:"$lastModifiedBy,omitempty"`
+	LastModifiedAt string `json:"$lastModifiedAt,omitempty"`
 }
 
 //CiList can contain a list of CiListEntries
@@ -63,7 +116,13 @@ type ciTrue struct {
 }
 
 //GetCi retrieves a CI fromm xld
+// when the ci does not exist the returned error satisfies IsNotFound
 func (r RepositoryServiceOp) GetCi(n string) (Ci, error) {
+	return r.GetCiContext(context.Background(), n)
+}
+
+//GetCiContext is GetCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) GetCiContext(ctx context.Context, n string) (Ci, error) {
 
 	var e map[string]interface{}
 	ri := make(map[string]interface{})
@@ -71,14 +130,17 @@ func (r RepositoryServiceOp) GetCi(n string) (Ci, error) {
 	var err error
 	var c Ci
 
-	if ok, _ := r.CiExists(n); ok != true {
-		s := fmt.Sprintf("CI: %s does not exists", n)
-		return c, errors.New(s)
+	_, err = validateID(n)
+	if err != nil {
+		return c, err
 	}
 
 	url := repositoryBasePath + "/" + "ci" + "/" + n
 
-	req, err := r.client.NewRequest(url, "GET", nil)
+	req, err := r.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return c, err
+	}
 
 	resp, err := r.client.Do(req, &e)
 
@@ -102,7 +164,7 @@ func (r RepositoryServiceOp) GetCi(n string) (Ci, error) {
 
 	// handle properties
 	//get property metadata for intended type
-	properties, _ := r.client.Meta.GetProperties(c.Type)
+	properties, _ := r.client.Meta.GetPropertiesContext(ctx, c.Type)
 
 	// loop over the properties and check if they where in the requested ci
 	for k := range properties {
@@ -118,30 +180,26 @@ func (r RepositoryServiceOp) GetCi(n string) (Ci, error) {
 
 //ListCis retrieves a list of Cis given a path in xld
 func (r RepositoryServiceOp) ListCis(n string) (CiList, error) {
+	return r.ListCisContext(context.Background(), n)
+}
 
-	var err error
-	var ciList []CiListEntry
-
-	url := repositoryBasePath + "/" + "query" + "?ancestor=/" + n
-
-	req, err := r.client.NewRequest(url, "GET", nil)
-
-	resp, err := r.client.Do(req, &ciList)
-
-	if err != nil {
-		return ciList, err
-	}
-
-	defer resp.Body.Close()
+//ListCisContext is ListCis with a context that is attached to every request it makes
+func (r RepositoryServiceOp) ListCisContext(ctx context.Context, n string) (CiList, error) {
 
-	return ciList, nil
+	return r.QueryContext(ctx, QueryOptions{Ancestor: n})
 }
 
 //NewCi creates a CI object
+// properties that are unknown for the type or can not be converted to their kind are reported in a *PropertiesError
 // n: name
 // t: type
 // p: properties
 func (r RepositoryServiceOp) NewCi(n string, t string, p map[string]interface{}) (Ci, error) {
+	return r.NewCiContext(context.Background(), n, t, p)
+}
+
+//NewCiContext is NewCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) NewCiContext(ctx context.Context, n string, t string, p map[string]interface{}) (Ci, error) {
 
 	var ci Ci
 
@@ -153,91 +211,45 @@ func (r RepositoryServiceOp) NewCi(n string, t string, p map[string]interface{})
 
 	ci.ID = n
 	ci.Type = t
-	ci.Properties = make(map[string]interface{})
 
 	//get metadata for intended type
-	metaData, _ := r.client.Meta.GetProperties(t)
-
-	//validate Properties
-	//loop over the metadata and see if the properties we got handed are actually the right type
-	// it they are the right type put them in the final map
-	for k, v := range p {
-		propType := metaData[k]
-		switch v := v.(type) {
-		case string:
-			if propType == "STRING" || propType == "CI" || propType == "ENUM" {
-
-				ci.Properties[k] = v
-			}
-		case bool:
-			if propType == "BOOLEAN" {
-
-				ci.Properties[k] = v
-			}
-		case int, float32, float64:
-			if propType == "INTEGER" {
-
-				ci.Properties[k] = int(v.(int))
-			}
-		case map[string]interface{}, map[string]string:
-			if propType == "MAP_STRING_STRING" {
-				ci.Properties[k] = v
-			}
-		case []string, []interface{}:
-			if propType == "SET_OF_STRING" || propType == "SET_OF_CI" {
-
-				ci.Properties[k] = v
-			}
-		default:
-			fmt.Printf("unexpected type %T\n", v) // %T prints whatever type t has
-		}
+	metaData, err := r.client.Meta.GetPropertiesContext(ctx, t)
+	if err != nil {
+		return ci, err
+	}
 
+	//convert the properties to the kind the metadata prescribes
+	ci.Properties, err = convertProperties(n, t, p, metaData)
+	if err != nil {
+		return ci, err
 	}
 
 	return ci, nil
 }
 
 //CreateCi  creates/updates a CI
+// when xldeploy rejects the ci a *ValidationError is returned listing the invalid properties
 // n: name
 // t: type
 // p: properties
 func (r RepositoryServiceOp) CreateCi(n string, t string, p map[string]interface{}) (Ci, error) {
+	return r.CreateCiContext(context.Background(), n, t, p)
+}
 
-	var dc Ci
-	var verb string
-
-	ci, err := r.TranslateCiProperties(n, t, p)
-	if err != nil {
-		return dc, err
-	}
-	//marshall the json and send it
-	url := repositoryBasePath + "/ci/" + n
-
-	exists, _ := r.CiExists(n)
-
-	if exists == true {
-		verb = "PUT"
-	} else {
-		verb = "POST"
-	}
-
-	req, err := r.client.NewRequest(url, verb, ci)
-	if err != nil {
-		return dc, err
-	}
-
-	_, err = r.client.Do(req, &dc)
-	if err != nil {
-		return dc, err
-	}
-
-	return dc, nil
+//CreateCiContext is CreateCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) CreateCiContext(ctx context.Context, n string, t string, p map[string]interface{}) (Ci, error) {
 
+	return r.save(ctx, Ci{ID: n, Type: t, Properties: p})
 }
 
 //TranslateCiProperties returns an object that can be encoded in XL-Deploy understandable json
+// properties that are unknown for the type or can not be converted to their kind are reported in a *PropertiesError
 func (r RepositoryServiceOp) TranslateCiProperties(n, t string, p map[string]interface{}) (map[string]interface{}, error) {
-	ci := make(map[string]interface{})
+	return r.TranslateCiPropertiesContext(context.Background(), n, t, p)
+}
+
+//TranslateCiPropertiesContext is TranslateCiProperties with a context that is attached to every request it makes
+func (r RepositoryServiceOp) TranslateCiPropertiesContext(ctx context.Context, n, t string, p map[string]interface{}) (map[string]interface{}, error) {
 
 	// validate the id: it needs to contain either Environments, Infrastructure, Applications
 	_, err := validateID(n)
@@ -245,60 +257,22 @@ func (r RepositoryServiceOp) TranslateCiProperties(n, t string, p map[string]int
 		return make(map[string]interface{}), err
 	}
 
-	ci["id"] = n
-	ci["type"] = t
-
 	//get metadata for intended type
-	metaData, _ := r.client.Meta.GetProperties(t)
-
-	//validate Properties
-	//loop over the metadata and see if the properties we got handed are actually the right type
-	// it they are the right type put them in the final map
-	for k, v := range p {
-		propType := metaData[k]
-		switch v := v.(type) {
-		default:
-			fmt.Printf("unexpected type %T\n", v) // %T prints whatever type t has
-		case string:
-			if propType == "STRING" || propType == "CI" || propType == "ENUM" {
-				if len(v) > 0 {
-					ci[k] = v
-				}
-			}
-		case bool:
-			if propType == "BOOLEAN" {
-				ci[k] = v
-			}
-		case int:
-			if propType == "INTEGER" {
-				ci[k] = v
-			}
-		case float32:
-			if propType == "INTEGER" {
-				ci[k] = v
-			}
-		case float64:
-			if propType == "INTEGER" {
-				ci[k] = v
-			}
-		case map[string]interface{}, map[string]string:
-			if propType == "MAP_STRING_STRING" {
-				ci[k] = v
-			}
-		case []string, []interface{}:
-			if propType == "SET_OF_STRING" || propType == "SET_OF_CI" {
-				if len(v.([]string)) > 0 {
-					ci[k] = v
-				}
-			}
-		}
-
+	metaData, err := r.client.Meta.GetPropertiesContext(ctx, t)
+	if err != nil {
+		return make(map[string]interface{}), err
 	}
-	return ci, nil
+
+	return translateCi(n, t, p, metaData)
 }
 
 //CiExists checks if a CI exists
 func (r RepositoryServiceOp) CiExists(n string) (bool, error) {
+	return r.CiExistsContext(context.Background(), n)
+}
+
+//CiExistsContext is CiExists with a context that is attached to every request it makes
+func (r RepositoryServiceOp) CiExistsContext(ctx context.Context, n string) (bool, error) {
 
 	var e ciTrue
 
@@ -310,7 +284,10 @@ func (r RepositoryServiceOp) CiExists(n string) (bool, error) {
 
 	url := repositoryBasePath + "/" + "exists" + "/" + n
 
-	req, err := r.client.NewRequest(url, "GET", nil)
+	req, err := r.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return false, err
+	}
 
 	resp, err := r.client.Do(req, &e)
 	if err != nil {
@@ -332,8 +309,131 @@ func (c Ci) Name() string {
 	return path.Base(c.ID)
 }
 
+//ciHeader holds the fixed part of a ci as xldeploy encodes it
+type ciHeader struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	Token          string `json:"$token,omitempty"`
+	CreatedBy      string `json:"$createdBy,omitempty"`
+	CreatedAt      string `json:"$createdAt,omitempty"`
+	LastModifiedBy string `json:"$lastModifiedBy,omitempty"`
+	LastModifiedAt string `json:"$lastModifiedAt,omitempty"`
+
+	ValidationMessages []ValidationMessage `json:"validation-messages,omitempty"`
+}
+
+var ciHeaderFields = []string{"id", "type", "$token", "$createdBy", "$createdAt", "$lastModifiedBy", "$lastModifiedAt", "validation-messages"}
+
+//MarshalJSON encodes the ci the way xldeploy expects it: properties live next to the id and type
+func (c Ci) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+
+	for k, v := range c.Properties {
+		m[k] = v
+	}
+
+	h, err := json.Marshal(ciHeader{
+		ID:             c.ID,
+		Type:           c.Type,
+		Token:          c.Token,
+		CreatedBy:      c.CreatedBy,
+		CreatedAt:      c.CreatedAt,
+		LastModifiedBy: c.LastModifiedBy,
+		LastModifiedAt: c.LastModifiedAt,
+
+		ValidationMessages: c.ValidationMessages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(h, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(m)
+}
+
+//UnmarshalJSON decodes a flat xldeploy ci. Everything that is not part of the header ends up in Properties
+func (c *Ci) UnmarshalJSON(b []byte) error {
+	var h ciHeader
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(b, &h); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	for _, f := range ciHeaderFields {
+		delete(m, f)
+	}
+
+	c.ID = h.ID
+	c.Type = h.Type
+	c.Token = h.Token
+	c.CreatedBy = h.CreatedBy
+	c.CreatedAt = h.CreatedAt
+	c.LastModifiedBy = h.LastModifiedBy
+	c.LastModifiedAt = h.LastModifiedAt
+	c.ValidationMessages = h.ValidationMessages
+	c.Properties = m
+
+	return nil
+}
+
 //private functions
 
+//save creates the ci when it does not exist yet and updates it otherwise
+func (r RepositoryServiceOp) save(ctx context.Context, c Ci) (Ci, error) {
+
+	var dc Ci
+	var verb string
+
+	if r.client.Config.ValidateBeforeSave {
+		if err := r.ValidateCiContext(ctx, c); err != nil {
+			return dc, err
+		}
+	}
+
+	ci, err := r.TranslateCiPropertiesContext(ctx, c.ID, c.Type, c.Properties)
+	if err != nil {
+		return dc, err
+	}
+	//marshall the json and send it
+	url := repositoryBasePath + "/ci/" + c.ID
+
+	exists, _ := r.CiExistsContext(ctx, c.ID)
+
+	if exists == true {
+		verb = "PUT"
+		// with the token xldeploy refuses the update when the ci changed since it was read
+		if c.Token != "" {
+			ci["$token"] = c.Token
+		}
+	} else {
+		verb = "POST"
+	}
+
+	req, err := r.client.NewRequestContext(ctx, url, verb, ci)
+	if err != nil {
+		return dc, err
+	}
+
+	_, err = r.client.Do(req, &dc)
+	if IsConflict(err) {
+		return dc, &ConflictError{ID: c.ID, Token: c.Token}
+	}
+	if err != nil {
+		return dc, validationError(err)
+	}
+
+	return dc, nil
+}
+
 func validateID(i string) (bool, error) {
 	validPrefix := [3]string{"Environments", "Infrastructure", "Applications"}
 
@@ -346,10 +446,189 @@ func validateID(i string) (bool, error) {
 	return false, errors.New("invalid ci id")
 }
 
+//translateCi builds the xldeploy json representation of a ci out of its properties and the property kinds of its type
+func translateCi(n, t string, p map[string]interface{}, metaData map[string]string) (map[string]interface{}, error) {
+
+	ci, err := convertProperties(n, t, p, metaData)
+	if err != nil {
+		return ci, err
+	}
+
+	ci["id"] = n
+	ci["type"] = t
+
+	return ci, nil
+}
+
 //SaveCi : Saves a ci object to the xld repository
+// when the ci carries a token and it was changed by someone else since it was read a *ConflictError is returned
 func (r RepositoryServiceOp) SaveCi(c Ci) (Ci, error) {
+	return r.SaveCiContext(context.Background(), c)
+}
+
+//SaveCiContext is SaveCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) SaveCiContext(ctx context.Context, c Ci) (Ci, error) {
+
+	return r.save(ctx, c)
+}
+
+//UpdateCiWithRetry reads a CI, applies mutate to it and saves it
+// when someone else changed the ci in the meantime it is read again and mutate is reapplied,
+// up to maxConflictRetries times. An error returned by mutate aborts the update
+func (r RepositoryServiceOp) UpdateCiWithRetry(n string, mutate func(*Ci) error) (Ci, error) {
+	return r.UpdateCiWithRetryContext(context.Background(), n, mutate)
+}
+
+//UpdateCiWithRetryContext is UpdateCiWithRetry with a context that is attached to every request it makes
+func (r RepositoryServiceOp) UpdateCiWithRetryContext(ctx context.Context, n string, mutate func(*Ci) error) (Ci, error) {
+
+	var c Ci
+	var err error
+
+	for i := 0; i <= maxConflictRetries; i++ {
+		c, err = r.GetCiContext(ctx, n)
+		if err != nil {
+			return c, err
+		}
+
+		if err = mutate(&c); err != nil {
+			return c, err
+		}
+
+		c, err = r.SaveCiContext(ctx, c)
+		if !IsConflict(err) {
+			return c, err
+		}
+	}
+
+	return c, err
+}
+
+//DeleteCi removes a CI and everything below it from the xld repository
+func (r RepositoryServiceOp) DeleteCi(n string) error {
+	return r.DeleteCiContext(context.Background(), n)
+}
+
+//DeleteCiContext is DeleteCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) DeleteCiContext(ctx context.Context, n string) error {
+
+	_, err := validateID(n)
+	if err != nil {
+		return err
+	}
+
+	url := repositoryBasePath + "/ci/" + n
+
+	req, err := r.client.NewRequestContext(ctx, url, "DELETE", nil)
+	if err != nil {
+		return err
+	}
 
-	return r.CreateCi(c.ID, c.Type, c.Properties)
+	_, err = r.client.Do(req, nil)
+
+	return err
+}
+
+//DeleteCis removes multiple CIs from the xld repository in one request
+func (r RepositoryServiceOp) DeleteCis(n ...string) error {
+	return r.DeleteCisContext(context.Background(), n...)
 }
 
-//
+//DeleteCisContext is DeleteCis with a context that is attached to every request it makes
+func (r RepositoryServiceOp) DeleteCisContext(ctx context.Context, n ...string) error {
+
+	for _, id := range n {
+		if _, err := validateID(id); err != nil {
+			return err
+		}
+	}
+
+	url := repositoryBasePath + "/cis/delete"
+
+	req, err := r.client.NewRequestContext(ctx, url, "POST", n)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.Do(req, nil)
+
+	return err
+}
+
+//MoveCi moves a CI to a new id
+// from: current id of the ci
+// to: the new id, this one can not exist yet
+func (r RepositoryServiceOp) MoveCi(from, to string) (Ci, error) {
+	return r.MoveCiContext(context.Background(), from, to)
+}
+
+//MoveCiContext is MoveCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) MoveCiContext(ctx context.Context, from, to string) (Ci, error) {
+	return r.relocate(ctx, "move", from, to, "newId", to)
+}
+
+//RenameCi gives a CI a new name, the ci stays under the same parent
+// n: id of the ci
+// name: the new name, without any path
+func (r RepositoryServiceOp) RenameCi(n, name string) (Ci, error) {
+	return r.RenameCiContext(context.Background(), n, name)
+}
+
+//RenameCiContext is RenameCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) RenameCiContext(ctx context.Context, n, name string) (Ci, error) {
+
+	if name == "" || strings.Contains(name, "/") {
+		return Ci{}, errors.New("invalid ci name")
+	}
+
+	return r.relocate(ctx, "rename", n, path.Dir(n)+"/"+name, "newName", name)
+}
+
+//CopyCi copies a CI and everything below it to a new id
+// from: id of the ci to copy
+// to: id of the copy, this one can not exist yet
+func (r RepositoryServiceOp) CopyCi(from, to string) (Ci, error) {
+	return r.CopyCiContext(context.Background(), from, to)
+}
+
+//CopyCiContext is CopyCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) CopyCiContext(ctx context.Context, from, to string) (Ci, error) {
+	return r.relocate(ctx, "copy", from, to, "newId", to)
+}
+
+//relocate handles move, rename and copy. They all take the ci id in the path and the target as a parameter
+func (r RepositoryServiceOp) relocate(ctx context.Context, action, from, to, param, value string) (Ci, error) {
+
+	var c Ci
+
+	for _, id := range []string{from, to} {
+		if _, err := validateID(id); err != nil {
+			return c, err
+		}
+	}
+
+	exists, err := r.CiExistsContext(ctx, to)
+	if err != nil {
+		return c, err
+	}
+	if exists {
+		return c, &AlreadyExistsError{Kind: "ci", ID: to}
+	}
+
+	q := url.Values{}
+	q.Set(param, value)
+
+	u := repositoryBasePath + "/" + action + "/" + from + "?" + q.Encode()
+
+	req, err := r.client.NewRequestContext(ctx, u, "POST", nil)
+	if err != nil {
+		return c, err
+	}
+
+	_, err = r.client.Do(req, &c)
+	if IsConflict(err) {
+		return c, &AlreadyExistsError{Kind: "ci", ID: to}
+	}
+
+	return c, err
+}
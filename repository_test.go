@@ -1,10 +1,12 @@
 package xld
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCreateGeneric(t *testing.T) {
@@ -51,6 +53,44 @@ func TestCreateGeneric(t *testing.T) {
 
 	}
 }
+
+func TestCreateCiValidationError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/ci/Infrastructure/testHost", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, mockTestInvalidHostResponse)
+	})
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+
+	_, err := client.Repository.CreateCi("Infrastructure/testHost", "overthere.SshHost", map[string]interface{}{"os": "UNIX"})
+
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError but got %v", err)
+	}
+
+	expected := []ValidationMessage{{
+		CiID:     "Infrastructure/testHost",
+		Property: "address",
+		Level:    "ERROR",
+		Message:  "Address is required",
+	}}
+
+	if v.CiID != "Infrastructure/testHost" || !reflect.DeepEqual(v.Messages, expected) {
+		t.Errorf("Expected validation messages %+v but got %+v", expected, v)
+	}
+
+	if !IsValidationError(err) {
+		t.Errorf("Expected IsValidationError to match %v", err)
+	}
+}
+
 func TestGetGeneric(t *testing.T) {
 	setup()
 	defer teardown()
@@ -88,9 +128,17 @@ func TestListCis(t *testing.T) {
 	//setup mock rest interfaces
 	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
 		testMethod(t, r, "GET")
+		if a := r.URL.Query().Get("ancestor"); a != "Environments" {
+			t.Errorf("Expected ancestor Environments but got %v", a)
+		}
 		fmt.Fprint(w, mockTestListResponse)
 	})
 
+	// a leading slash is dropped, the ancestor is a plain ci id
+	if _, err := client.Repository.ListCis("/Environments"); err != nil {
+		t.Errorf("repository.ListCis returned error: %v", err)
+	}
+
 	//use the GetGeneric function
 	acct, err := client.Repository.ListCis("Environments")
 	if err != nil {
@@ -106,6 +154,60 @@ func TestListCis(t *testing.T) {
 
 }
 
+func TestQueryAll(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+
+		q := r.URL.Query()
+		if q.Get("type") != "udm.Dictionary" || q.Get("parent") != "Environments" || q.Get("resultsPerPage") != "2" {
+			t.Errorf("Unexpected query %v", r.URL.RawQuery)
+		}
+
+		if q.Get("lastModifiedAfter") != "2016-09-27T09:42:58.212+0000" {
+			t.Errorf("Expected lastModifiedAfter 2016-09-27T09:42:58.212+0000 but got %v", q.Get("lastModifiedAfter"))
+		}
+
+		switch q.Get("page") {
+		case "":
+			fmt.Fprint(w, `[{"ref":"Environments/test1","type":"udm.Dictionary"},{"ref":"Environments/test2","type":"udm.Dictionary"}]`)
+		case "1":
+			fmt.Fprint(w, `[{"ref":"Environments/test3","type":"udm.Dictionary","$lastModifiedAt":"2016-09-28T09:42:58.212+0200"}]`)
+		default:
+			t.Errorf("Expected no request after the last page, got page %v", q.Get("page"))
+		}
+	})
+
+	it := client.Repository.QueryAll(QueryOptions{
+		Type:              "udm.Dictionary",
+		Parent:            "Environments",
+		LastModifiedAfter: time.Date(2016, 9, 27, 9, 42, 58, 212000000, time.UTC),
+		ResultsPerPage:    2,
+	})
+
+	var ids []string
+	var last CiListEntry
+
+	for it.Next() {
+		last = it.Entry()
+		ids = append(ids, last.ID)
+	}
+
+	if it.Err() != nil {
+		t.Errorf("repository.QueryAll returned error: %v", it.Err())
+	}
+
+	if !reflect.DeepEqual(ids, []string{"Environments/test1", "Environments/test2", "Environments/test3"}) {
+		t.Errorf("Expected all three cis but got %v", ids)
+	}
+
+	if last.LastModifiedAt != "2016-09-28T09:42:58.212+0200" {
+		t.Errorf("Expected $lastModifiedAt to be decoded but got %q", last.LastModifiedAt)
+	}
+}
+
 func ciInList(c CiListEntry, l CiList) bool {
 	for _, ci := range l {
 		if c.ID == ci.ID && ci.Type == c.Type {
@@ -162,6 +264,252 @@ func TestCiExists(t *testing.T) {
 
 }
 
+func TestDeleteCis(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var deleted []string
+
+	mux.HandleFunc("/deployit/repository/cis/delete", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		json.NewDecoder(r.Body).Decode(&deleted)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := client.Repository.DeleteCis("Environments/test1", "Environments/test2")
+	if err != nil {
+		t.Errorf("repository.DeleteCis returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(deleted, []string{"Environments/test1", "Environments/test2"}) {
+		t.Errorf("Expected both cis to be deleted but got %v", deleted)
+	}
+
+	err = client.Repository.DeleteCis("Environments/test1", "bogus")
+	if err == nil {
+		t.Error("Expected an error for an invalid ci id")
+	}
+}
+
+func TestRelocateCi(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/exists/Environments/taken", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{ "boolean" : true}`)
+	})
+	mux.HandleFunc("/deployit/repository/exists/Environments/renamed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{ "boolean" : false}`)
+	})
+	mux.HandleFunc("/deployit/repository/rename/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		if n := r.URL.Query().Get("newName"); n != "renamed" {
+			t.Errorf("Expected newName renamed but got %v", n)
+		}
+
+		fmt.Fprint(w, `{"id": "Environments/renamed", "type": "udm.Dictionary"}`)
+	})
+	mux.HandleFunc("/deployit/repository/move/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no move when the target exists")
+	})
+
+	c, err := client.Repository.RenameCi("Environments/testDictionary1", "renamed")
+	if err != nil {
+		t.Errorf("repository.RenameCi returned error: %v", err)
+	}
+
+	if c.ID != "Environments/renamed" {
+		t.Errorf("Expected Environments/renamed but got %v", c.ID)
+	}
+
+	_, err = client.Repository.MoveCi("Environments/testDictionary1", "Environments/taken")
+	if !IsAlreadyExists(err) {
+		t.Errorf("Expected an AlreadyExistsError but got %v", err)
+	}
+}
+
+func TestCreateCis(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/udm.Dictionary", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestDictionaryMetaResponse)
+	})
+
+	mux.HandleFunc("/deployit/repository/cis", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var cis []Ci
+		json.NewDecoder(r.Body).Decode(&cis)
+
+		if len(cis) != 2 {
+			t.Fatalf("Expected 2 cis in one request but got %v", len(cis))
+		}
+
+		// the second ci is rejected
+		cis[1].ValidationMessages = []ValidationMessage{{CiID: cis[1].ID, Property: "entries", Level: "ERROR", Message: "Entries are required"}}
+
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(cis)
+	})
+
+	cis := []Ci{
+		{ID: "Environments/test1", Type: "udm.Dictionary", Properties: map[string]interface{}{"entries": map[string]string{"a": "b"}}},
+		{ID: "Environments/test2", Type: "udm.Dictionary"},
+	}
+
+	_, err := client.Repository.CreateCis(cis)
+
+	be, ok := err.(*BatchValidationError)
+	if !ok {
+		t.Fatalf("Expected a *BatchValidationError but got %v", err)
+	}
+
+	if len(be.Errors) != 2 || be.Errors[0] != nil || be.Errors[1] == nil {
+		t.Fatalf("Expected only the second ci to be invalid but got %+v", be.Errors)
+	}
+
+	if be.Errors[1].CiID != "Environments/test2" || be.Errors[1].Messages[0].Property != "entries" {
+		t.Errorf("Expected entries of Environments/test2 to be invalid but got %+v", be.Errors[1])
+	}
+}
+
+func TestUpdateCisConflict(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/udm.Dictionary", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestDictionaryMetaResponse)
+	})
+
+	mux.HandleFunc("/deployit/repository/cis", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	cis := []Ci{
+		{ID: "Environments/test1", Type: "udm.Dictionary", Token: "stale"},
+		{ID: "Environments/test2", Type: "udm.Dictionary", Token: "stale"},
+	}
+
+	_, err := client.Repository.UpdateCis(cis)
+
+	ce, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("Expected a *ConflictError but got %v", err)
+	}
+
+	if !reflect.DeepEqual(ce.IDs, []string{"Environments/test1", "Environments/test2"}) {
+		t.Errorf("Expected the ids of the cis that were sent but got %v", ce.IDs)
+	}
+
+	if !IsConflict(err) {
+		t.Errorf("IsConflict returned false for %v", err)
+	}
+}
+
+func TestGetCis(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/cis/read", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprint(w, "["+mockTestDictionaryResponse+"]")
+	})
+
+	cis, err := client.Repository.GetCis("Environments/testDictionary1")
+	if err != nil {
+		t.Errorf("repository.GetCis returned error: %v", err)
+	}
+
+	if len(cis) != 1 || cis[0].ID != "Environments/testDictionary1" || cis[0].Token != "7f5eeb79-73f9-4312-a4d3-0363402c109d" {
+		t.Errorf("Expected Environments/testDictionary1 but got %+v", cis)
+	}
+}
+
+func TestUpdateCiWithRetry(t *testing.T) {
+	setup()
+	defer teardown()
+
+	tokens := []string{"token-1", "token-2"}
+	reads := 0
+	saves := 0
+
+	mux.HandleFunc("/deployit/metadata/type/udm.Dictionary", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestDictionaryMetaResponse)
+	})
+	mux.HandleFunc("/deployit/repository/exists/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{ "boolean" : true}`)
+	})
+	mux.HandleFunc("/deployit/repository/ci/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `{"id": "Environments/testDictionary1", "type": "udm.Dictionary", "$token": %q, "entries": {"a": "b"}}`, tokens[reads])
+			reads++
+		case "PUT":
+			var c Ci
+			json.NewDecoder(r.Body).Decode(&c)
+			saves++
+
+			// somebody else changed the ci after the first read
+			if c.Token != tokens[len(tokens)-1] {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, "Repository entity Environments/testDictionary1 has been updated since you read it")
+				return
+			}
+
+			json.NewEncoder(w).Encode(c)
+		}
+	})
+
+	c, err := client.Repository.UpdateCiWithRetry("Environments/testDictionary1", func(c *Ci) error {
+		c.Properties["entries"] = map[string]string{"a": "c"}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("repository.UpdateCiWithRetry returned error: %v", err)
+	}
+
+	if reads != 2 || saves != 2 {
+		t.Errorf("Expected 2 reads and 2 saves but got %v and %v", reads, saves)
+	}
+
+	if !reflect.DeepEqual(c.Properties["entries"], map[string]interface{}{"a": "c"}) {
+		t.Errorf("Expected the mutation to be saved but got %v", c.Properties["entries"])
+	}
+}
+
+func TestSaveCiConflict(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/udm.Dictionary", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestDictionaryMetaResponse)
+	})
+	mux.HandleFunc("/deployit/repository/exists/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{ "boolean" : true}`)
+	})
+	mux.HandleFunc("/deployit/repository/ci/Environments/testDictionary1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+
+		var c map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&c)
+
+		if c["$token"] != "stale" {
+			t.Errorf("Expected the token to be sent but got %v", c["$token"])
+		}
+
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	_, err := client.Repository.SaveCi(Ci{ID: "Environments/testDictionary1", Type: "udm.Dictionary", Token: "stale"})
+
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("Expected a *ConflictError but got %v", err)
+	}
+}
+
 // response variables
 func getDictionaryCiStruct() Ci {
 
@@ -304,6 +652,33 @@ var mockTestDictionaryMetaResponse = `{
   ]
 }`
 
+var mockTestInvalidHostResponse = `{
+  "id": "Infrastructure/testHost",
+  "type": "overthere.SshHost",
+  "os": "UNIX",
+  "validation-messages": [
+    {"ci": "Infrastructure/testHost", "property": "address", "level": "ERROR", "message": "Address is required"}
+  ]
+}`
+
+var mockTestSshHostMetaResponse = `{
+  "type": "overthere.SshHost",
+  "virtual": false,
+  "root": "Infrastructure",
+  "description": "A machine that runs unix or windows and is reached over ssh",
+  "properties": [
+    {"name": "os", "kind": "ENUM", "required": true, "enumValues": ["WINDOWS", "UNIX", "ZOS"], "default": "UNIX"},
+    {"name": "connectionType", "kind": "ENUM", "required": true, "enumValues": ["SFTP", "SCP", "SUDO", "INTERACTIVE_SUDO"], "default": "SFTP"},
+    {"name": "address", "kind": "STRING", "required": true},
+    {"name": "port", "kind": "INTEGER", "required": true, "default": 22},
+    {"name": "username", "kind": "STRING", "required": false},
+    {"name": "password", "kind": "STRING", "required": false, "password": true},
+    {"name": "tags", "kind": "SET_OF_STRING", "required": false}
+  ],
+  "interfaces": ["udm.Taggable", "udm.ConfigurationItem", "udm.Container"],
+  "superTypes": ["overthere.Host", "udm.BaseContainer", "udm.BaseConfigurationItem"]
+}`
+
 var mockTestListResponse = `
 [{"ref":"Environments/Wian","type":"udm.Dictionary"},
 {"ref":"Environments/Wian2","type":"udm.Dictionary"},
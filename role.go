@@ -0,0 +1,127 @@
+package xld
+
+import (
+	"context"
+	"io/ioutil"
+)
+
+//Role is a role in xldeploy together with the principals (users or groups) assigned to it
+type Role struct {
+	ID         int      `json:"id,omitempty"`
+	Name       string   `json:"name"`
+	Principals []string `json:"principals,omitempty"`
+}
+
+//rolePrincipals is the way xldeploy reports a role with its principals
+type rolePrincipals struct {
+	Role       Role     `json:"role"`
+	Principals []string `json:"principals"`
+}
+
+//ListRoles returns all roles with the principals assigned to them
+func (s SecurityServiceOp) ListRoles() ([]Role, error) {
+	return s.ListRolesContext(context.Background())
+}
+
+//ListRolesContext is ListRoles with a context that is attached to every request it makes
+func (s SecurityServiceOp) ListRolesContext(ctx context.Context) ([]Role, error) {
+	var rp []rolePrincipals
+
+	url := securityBasePath + "/role/principals"
+
+	req, err := s.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.client.Do(req, &rp)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, len(rp))
+	for i, r := range rp {
+		roles[i] = r.Role
+		roles[i].Principals = r.Principals
+	}
+
+	return roles, nil
+}
+
+//CreateRole creates a role without principals
+func (s SecurityServiceOp) CreateRole(n string) error {
+	return s.CreateRoleContext(context.Background(), n)
+}
+
+//CreateRoleContext is CreateRole with a context that is attached to every request it makes
+func (s SecurityServiceOp) CreateRoleContext(ctx context.Context, n string) error {
+	return s.roleAction(ctx, "PUT", "/role/"+n)
+}
+
+//DeleteRole removes a role and all permissions granted to it
+func (s SecurityServiceOp) DeleteRole(n string) error {
+	return s.DeleteRoleContext(context.Background(), n)
+}
+
+//DeleteRoleContext is DeleteRole with a context that is attached to every request it makes
+func (s SecurityServiceOp) DeleteRoleContext(ctx context.Context, n string) error {
+	return s.roleAction(ctx, "DELETE", "/role/"+n)
+}
+
+//RenameRole renames role n to name, keeping its principals and permissions
+func (s SecurityServiceOp) RenameRole(n, name string) error {
+	return s.RenameRoleContext(context.Background(), n, name)
+}
+
+//RenameRoleContext is RenameRole with a context that is attached to every request it makes
+func (s SecurityServiceOp) RenameRoleContext(ctx context.Context, n, name string) error {
+	return s.roleAction(ctx, "POST", "/role/"+n+"/"+name)
+}
+
+//AssignPrincipals assigns the principals to role n
+func (s SecurityServiceOp) AssignPrincipals(n string, principals ...string) error {
+	return s.AssignPrincipalsContext(context.Background(), n, principals...)
+}
+
+//AssignPrincipalsContext is AssignPrincipals with a context that is attached to every request it makes
+func (s SecurityServiceOp) AssignPrincipalsContext(ctx context.Context, n string, principals ...string) error {
+	for _, p := range principals {
+		if err := s.roleAction(ctx, "PUT", "/role/"+n+"/"+p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//UnassignPrincipals removes the principals from role n
+func (s SecurityServiceOp) UnassignPrincipals(n string, principals ...string) error {
+	return s.UnassignPrincipalsContext(context.Background(), n, principals...)
+}
+
+//UnassignPrincipalsContext is UnassignPrincipals with a context that is attached to every request it makes
+func (s SecurityServiceOp) UnassignPrincipalsContext(ctx context.Context, n string, principals ...string) error {
+	for _, p := range principals {
+		if err := s.roleAction(ctx, "DELETE", "/role/"+n+"/"+p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//private functions
+
+//roleAction sends a request to the security api that has no body and no meaningful response
+func (s SecurityServiceOp) roleAction(ctx context.Context, verb, path string) error {
+	url := securityBasePath + path
+
+	req, err := s.client.NewRequestContext(ctx, url, verb, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Do(req, ioutil.Discard)
+
+	return err
+}
@@ -1,8 +1,8 @@
 package xld
 
 import (
-	"errors"
-	"fmt"
+	"context"
+	"io/ioutil"
 )
 
 const (
@@ -12,9 +12,43 @@ const (
 //SecurityService represents the service for engaging the XL-Deploy security rest interface
 type SecurityService interface {
 	GetUser(n string) (User, error)
-	UserExists(n string) bool
+	GetUserContext(ctx context.Context, n string) (User, error)
+	UserExists(n string) (bool, error)
+	UserExistsContext(ctx context.Context, n string) (bool, error)
+	ListUsers() ([]string, error)
+	ListUsersContext(ctx context.Context) ([]string, error)
 	CreateUser(n string, a bool) (User, error)
+	CreateUserContext(ctx context.Context, n string, a bool) (User, error)
 	SetPasswordForUser(n, p string) error
+	SetPasswordForUserContext(ctx context.Context, n, p string) error
+	UpdateUser(u User) (User, error)
+	UpdateUserContext(ctx context.Context, u User) (User, error)
+	DeleteUser(n string) error
+	DeleteUserContext(ctx context.Context, n string) error
+	ChangeOwnPassword(oldPassword, newPassword string) error
+	ChangeOwnPasswordContext(ctx context.Context, oldPassword, newPassword string) error
+	ListRoles() ([]Role, error)
+	ListRolesContext(ctx context.Context) ([]Role, error)
+	CreateRole(n string) error
+	CreateRoleContext(ctx context.Context, n string) error
+	DeleteRole(n string) error
+	DeleteRoleContext(ctx context.Context, n string) error
+	RenameRole(n, name string) error
+	RenameRoleContext(ctx context.Context, n, name string) error
+	AssignPrincipals(n string, principals ...string) error
+	AssignPrincipalsContext(ctx context.Context, n string, principals ...string) error
+	UnassignPrincipals(n string, principals ...string) error
+	UnassignPrincipalsContext(ctx context.Context, n string, principals ...string) error
+	ListPermissions(role string) ([]Permission, error)
+	ListPermissionsContext(ctx context.Context, role string) ([]Permission, error)
+	GetPermissions(role, ciID string) ([]Permission, error)
+	GetPermissionsContext(ctx context.Context, role, ciID string) ([]Permission, error)
+	GrantPermission(p Permission) error
+	GrantPermissionContext(ctx context.Context, p Permission) error
+	RevokePermission(p Permission) error
+	RevokePermissionContext(ctx context.Context, p Permission) error
+	CheckPermission(permission, ciID string) (bool, error)
+	CheckPermissionContext(ctx context.Context, permission, ciID string) (bool, error)
 }
 
 //SecurityServiceOp holds the communication service for the Security rest api
@@ -31,51 +65,96 @@ type User struct {
 	Password string `json:"password,omitempty"`
 }
 
+//passwordChange is the body xldeploy expects when users change their own password
+type passwordChange struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
 //GetUser returns a user from xld
+// a user that does not exist results in an error for which IsNotFound returns true
 func (s SecurityServiceOp) GetUser(n string) (User, error) {
+	return s.GetUserContext(context.Background(), n)
+}
+
+//GetUserContext is GetUser with a context that is attached to every request it makes
+func (s SecurityServiceOp) GetUserContext(ctx context.Context, n string) (User, error) {
 
 	var u User
 
 	url := securityBasePath + "/user/" + n
 
-	req, err := s.client.NewRequest(url, "GET", nil)
+	req, err := s.client.NewRequestContext(ctx, url, "GET", nil)
 
 	if err != nil {
 		return u, err
 	}
 
-	resp, err := s.client.Do(req, &u)
+	_, err = s.client.Do(req, &u)
+
+	return u, err
+}
+
+//UserExists check if a user exists in the XL-Deploy Repository
+// any failure other than the user not being there is returned as error
+// only applies to the internal xldeploy repository
+func (s SecurityServiceOp) UserExists(n string) (bool, error) {
+	return s.UserExistsContext(context.Background(), n)
+}
 
+//UserExistsContext is UserExists with a context that is attached to every request it makes
+func (s SecurityServiceOp) UserExistsContext(ctx context.Context, n string) (bool, error) {
+	_, err := s.GetUserContext(ctx, n)
+	if IsNotFound(err) {
+		return false, nil
+	}
 	if err != nil {
-		return u, err
+		return false, err
 	}
-	defer resp.Body.Close()
 
-	return u, nil
+	return true, nil
 }
 
-//UserExists check if a user exists in the XL-Deploy Repository
-// returns true if it does
-// returns false if anything goes wrong (!!!)
-// only applies to the internal xldeploy repository
-func (s SecurityServiceOp) UserExists(n string) bool {
-	_, err := s.GetUser(n)
+//ListUsers returns the names of all users in the XL-Deploy repository
+func (s SecurityServiceOp) ListUsers() ([]string, error) {
+	return s.ListUsersContext(context.Background())
+}
+
+//ListUsersContext is ListUsers with a context that is attached to every request it makes
+func (s SecurityServiceOp) ListUsersContext(ctx context.Context) ([]string, error) {
+	var users []string
+
+	url := securityBasePath + "/user"
+
+	req, err := s.client.NewRequestContext(ctx, url, "GET", nil)
 	if err != nil {
-		return false
+		return users, err
 	}
 
-	return true
+	_, err = s.client.Do(req, &users)
+
+	return users, err
 }
 
 //CreateUser creates a user in the XL-Deploy repository
 // n is the name of the user
 // a signified if the user should be admin
+// an *AlreadyExistsError is returned when the user is already there
 func (s SecurityServiceOp) CreateUser(n string, a bool) (User, error) {
+	return s.CreateUserContext(context.Background(), n, a)
+}
+
+//CreateUserContext is CreateUser with a context that is attached to every request it makes
+func (s SecurityServiceOp) CreateUserContext(ctx context.Context, n string, a bool) (User, error) {
 	var u User
 
 	// check if the user already exists. If so return an error saying just that
-	if s.UserExists(n) {
-		return u, errors.New("user already exists")
+	exists, err := s.UserExistsContext(ctx, n)
+	if err != nil {
+		return u, err
+	}
+	if exists {
+		return u, &AlreadyExistsError{Kind: "user", ID: n}
 	}
 
 	// if we made it this far it is time to set up the user
@@ -84,51 +163,101 @@ func (s SecurityServiceOp) CreateUser(n string, a bool) (User, error) {
 
 	url := securityBasePath + "/user/" + n
 
-	req, err := s.client.NewRequest(url, "POST", u)
+	req, err := s.client.NewRequestContext(ctx, url, "POST", u)
 
 	if err != nil {
 		return u, err
 	}
 
-	resp, err := s.client.Do(req, &u)
+	_, err = s.client.Do(req, &u)
 
-	if err != nil {
-		return u, err
-	}
-	defer resp.Body.Close()
-
-	return u, nil
+	return u, err
 }
 
 //SetPasswordForUser updates an already existing user with a password
 // this can be setting the password for the first time, or setting a new one
+// setting the password of another user requires admin rights, use ChangeOwnPassword otherwise
 func (s SecurityServiceOp) SetPasswordForUser(n, p string) error {
-	var u User
+	return s.SetPasswordForUserContext(context.Background(), n, p)
+}
 
-	if s.UserExists(n) == false {
-		return errors.New("user does not exists, unable to set password")
+//SetPasswordForUserContext is SetPasswordForUser with a context that is attached to every request it makes
+func (s SecurityServiceOp) SetPasswordForUserContext(ctx context.Context, n, p string) error {
+	u, err := s.GetUserContext(ctx, n)
+	if err != nil {
+		return err
 	}
 
-	u, _ = s.GetUser(n)
-
 	u.Password = p
-	url := securityBasePath + "/user/" + n
 
-	fmt.Printf("%+v\n", u)
-	fmt.Println(url)
-	req, err := s.client.NewRequest(url, "PUT", u)
+	_, err = s.UpdateUserContext(ctx, u)
+
+	return err
+}
+
+//UpdateUser updates the admin flag of an existing user, and its password when u.Password is set
+func (s SecurityServiceOp) UpdateUser(u User) (User, error) {
+	return s.UpdateUserContext(context.Background(), u)
+}
+
+//UpdateUserContext is UpdateUser with a context that is attached to every request it makes
+func (s SecurityServiceOp) UpdateUserContext(ctx context.Context, u User) (User, error) {
+	var ru User
+
+	url := securityBasePath + "/user/" + u.Username
+
+	req, err := s.client.NewRequestContext(ctx, url, "PUT", u)
+	if err != nil {
+		return ru, err
+	}
+
+	_, err = s.client.Do(req, &ru)
+
+	return ru, err
+}
+
+//DeleteUser removes a user from the XL-Deploy repository
+func (s SecurityServiceOp) DeleteUser(n string) error {
+	return s.DeleteUserContext(context.Background(), n)
+}
+
+//DeleteUserContext is DeleteUser with a context that is attached to every request it makes
+func (s SecurityServiceOp) DeleteUserContext(ctx context.Context, n string) error {
+	url := securityBasePath + "/user/" + n
 
+	req, err := s.client.NewRequestContext(ctx, url, "DELETE", nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := s.client.Do(req, &u)
+	_, err = s.client.Do(req, ioutil.Discard)
+
+	return err
+}
+
+//ChangeOwnPassword changes the password of the user the client is logged in with
+// xldeploy verifies oldPassword before it accepts newPassword, which lets users without admin rights change their password
+// on success the client continues with the new password, it is safe to call while other requests are in flight
+func (s SecurityServiceOp) ChangeOwnPassword(oldPassword, newPassword string) error {
+	return s.ChangeOwnPasswordContext(context.Background(), oldPassword, newPassword)
+}
 
+//ChangeOwnPasswordContext is ChangeOwnPassword with a context that is attached to every request it makes
+func (s SecurityServiceOp) ChangeOwnPasswordContext(ctx context.Context, oldPassword, newPassword string) error {
+	user, _ := s.client.credentials()
+	url := securityBasePath + "/user/" + user + "/password"
+
+	req, err := s.client.NewRequestContext(ctx, url, "PUT", passwordChange{OldPassword: oldPassword, NewPassword: newPassword})
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	return nil
+	_, err = s.client.Do(req, ioutil.Discard)
+	if err != nil {
+		return err
+	}
+
+	s.client.setPassword(newPassword)
 
+	return nil
 }
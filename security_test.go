@@ -0,0 +1,206 @@
+package xld
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListRoles(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/security/role/principals", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, mockTestRolePrincipalsResponse)
+	})
+
+	roles, err := client.Security.ListRoles()
+	if err != nil {
+		t.Fatalf("ListRoles returned error: %v", err)
+	}
+
+	expected := []Role{
+		{ID: 1, Name: "deployers", Principals: []string{"jenkins", "ops"}},
+		{ID: 2, Name: "viewers", Principals: []string{}},
+	}
+
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("ListRoles returned %+v, expected %+v", roles, expected)
+	}
+}
+
+func TestRoleActions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var requests []string
+	mux.HandleFunc("/deployit/security/role/", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	})
+
+	if err := client.Security.CreateRole("deployers"); err != nil {
+		t.Errorf("CreateRole returned error: %v", err)
+	}
+	if err := client.Security.AssignPrincipals("deployers", "jenkins", "ops"); err != nil {
+		t.Errorf("AssignPrincipals returned error: %v", err)
+	}
+	if err := client.Security.RenameRole("deployers", "releasers"); err != nil {
+		t.Errorf("RenameRole returned error: %v", err)
+	}
+	if err := client.Security.DeleteRole("releasers"); err != nil {
+		t.Errorf("DeleteRole returned error: %v", err)
+	}
+
+	expected := []string{
+		"PUT /deployit/security/role/deployers",
+		"PUT /deployit/security/role/deployers/jenkins",
+		"PUT /deployit/security/role/deployers/ops",
+		"POST /deployit/security/role/deployers/releasers",
+		"DELETE /deployit/security/role/releasers",
+	}
+
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected requests %v but got %v", expected, requests)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/security/granted-permissions/role/deployers", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"": ["login"], "Environments/dev": ["read", "deploy#initial"]}`)
+	})
+
+	p, err := client.Security.GetPermissions("deployers", "Environments/dev")
+	if err != nil {
+		t.Fatalf("GetPermissions returned error: %v", err)
+	}
+
+	expected := []Permission{
+		{Role: "deployers", Permission: "deploy#initial", CiID: "Environments/dev"},
+		{Role: "deployers", Permission: "read", CiID: "Environments/dev"},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("GetPermissions returned %v, expected %v", p, expected)
+	}
+
+	granted := false
+	mux.HandleFunc("/deployit/security/permission/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		if r.URL.Path != "/deployit/security/permission/repo#edit/deployers/Environments/dev" {
+			t.Errorf("Unexpected permission path %v", r.URL.Path)
+		}
+		granted = true
+	})
+
+	err = client.Security.GrantPermission(Permission{Role: "deployers", Permission: "repo#edit", CiID: "Environments/dev"})
+	if err != nil || !granted {
+		t.Errorf("GrantPermission returned %v, granted %v", err, granted)
+	}
+
+	mux.HandleFunc("/deployit/security/check/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "true")
+	})
+
+	ok, err := client.Security.CheckPermission("deploy#initial", "Environments/dev")
+	if err != nil || !ok {
+		t.Errorf("CheckPermission returned %v, %v", ok, err)
+	}
+}
+
+func TestUserExists(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/security/user/admin", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"username": "admin", "admin": true}`)
+	})
+	mux.HandleFunc("/deployit/security/user/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "repository unavailable", http.StatusInternalServerError)
+	})
+
+	cases := map[string]bool{"admin": true, "unknown": false}
+	for n, expected := range cases {
+		exists, err := client.Security.UserExists(n)
+		if err != nil || exists != expected {
+			t.Errorf("UserExists(%v) returned %v, %v, expected %v", n, exists, err, expected)
+		}
+	}
+
+	if _, err := client.Security.UserExists("broken"); err == nil {
+		t.Error("Expected UserExists to return the server error")
+	}
+
+	if _, err := client.Security.CreateUser("admin", true); !IsAlreadyExists(err) {
+		t.Errorf("Expected CreateUser to return an AlreadyExistsError but got %v", err)
+	}
+}
+
+func TestListAndDeleteUsers(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/security/user", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `["admin", "jenkins"]`)
+	})
+
+	deleted := false
+	mux.HandleFunc("/deployit/security/user/jenkins", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		deleted = true
+	})
+
+	users, err := client.Security.ListUsers()
+	if err != nil || !reflect.DeepEqual(users, []string{"admin", "jenkins"}) {
+		t.Errorf("ListUsers returned %v, %v", users, err)
+	}
+
+	if err := client.Security.DeleteUser("jenkins"); err != nil || !deleted {
+		t.Errorf("DeleteUser returned %v, deleted %v", err, deleted)
+	}
+}
+
+func TestChangeOwnPassword(t *testing.T) {
+	setup()
+	defer teardown()
+	defer func() { mockConfig.Password = "password" }()
+
+	mux.HandleFunc("/deployit/security/user/admin/password", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+
+		var p passwordChange
+		json.NewDecoder(r.Body).Decode(&p)
+		if p.OldPassword != "password" || p.NewPassword != "secret" {
+			t.Errorf("Unexpected password change %+v", p)
+		}
+	})
+
+	// requests that are built meanwhile read the credentials, go test -race catches unguarded access
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			client.NewRequest("deployit/server/info", "GET", nil)
+		}
+	}()
+
+	if err := client.Security.ChangeOwnPassword("password", "secret"); err != nil {
+		t.Fatalf("ChangeOwnPassword returned error: %v", err)
+	}
+	<-done
+
+	if client.Config.Password != "secret" {
+		t.Errorf("Expected the client to use the new password but got %v", client.Config.Password)
+	}
+}
+
+var mockTestRolePrincipalsResponse = `[
+  {"role": {"id": 1, "name": "deployers"}, "principals": ["jenkins", "ops"]},
+  {"role": {"id": 2, "name": "viewers"}, "principals": []}
+]`
@@ -0,0 +1,388 @@
+package xld
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+//SecurityState is the desired access model of an xldeploy server
+// users are created or updated, roles are matched on their name
+type SecurityState struct {
+	Users []User      `json:"users,omitempty"`
+	Roles []RoleState `json:"roles,omitempty"`
+}
+
+//RoleState is the desired state of a single role
+// Permissions are the global permissions, CiPermissions maps ci ids onto the permissions on that ci
+type RoleState struct {
+	Name          string              `json:"name"`
+	Principals    []string            `json:"principals,omitempty"`
+	Permissions   []string            `json:"permissions,omitempty"`
+	CiPermissions map[string][]string `json:"ciPermissions,omitempty"`
+}
+
+//SecurityAction is the kind of change a SecurityChange makes
+type SecurityAction string
+
+//The changes a security plan can hold, in the order they are applied
+const (
+	ActionCreateUser        SecurityAction = "create user"
+	ActionUpdateUser        SecurityAction = "update user"
+	ActionCreateRole        SecurityAction = "create role"
+	ActionAssignPrincipal   SecurityAction = "assign principal"
+	ActionUnassignPrincipal SecurityAction = "unassign principal"
+	ActionGrantPermission   SecurityAction = "grant permission"
+	ActionRevokePermission  SecurityAction = "revoke permission"
+	ActionDeleteRole        SecurityAction = "delete role"
+)
+
+//SecurityChange is a single change needed to bring the server to the desired state
+type SecurityChange struct {
+	Action     SecurityAction
+	User       User
+	Role       string
+	Principal  string
+	Permission Permission
+}
+
+//SecurityPlan holds the changes needed to bring the server to the desired state
+type SecurityPlan struct {
+	Changes []SecurityChange
+}
+
+//SecurityReconciler brings the users, roles and permissions of a server in line with a SecurityState
+type SecurityReconciler struct {
+	Security SecurityService
+	// Prune removes the roles on the server that are not part of the desired state
+	Prune bool
+}
+
+//LoadSecurityState reads a SecurityState from json or yaml, the format is detected from the content
+// yaml uses the same keys as json
+func LoadSecurityState(r io.Reader) (SecurityState, error) {
+	var s SecurityState
+
+	err := decodeJSONOrYAML(r, &s)
+
+	return s, err
+}
+
+//NewSecurityReconciler returns a reconciler that works through s
+func NewSecurityReconciler(s SecurityService) *SecurityReconciler {
+	return &SecurityReconciler{Security: s}
+}
+
+//Plan compares the desired state with the server and returns the changes needed
+// users are only created or updated, passwords are only set for new users
+func (r *SecurityReconciler) Plan(desired SecurityState) (SecurityPlan, error) {
+	return r.PlanContext(context.Background(), desired)
+}
+
+//PlanContext is Plan with a context that is attached to every request it makes
+func (r *SecurityReconciler) PlanContext(ctx context.Context, desired SecurityState) (SecurityPlan, error) {
+	var p SecurityPlan
+
+	for _, u := range desired.Users {
+		current, err := r.Security.GetUserContext(ctx, u.Username)
+		if IsNotFound(err) {
+			p.add(SecurityChange{Action: ActionCreateUser, User: u})
+			continue
+		}
+		if err != nil {
+			return p, err
+		}
+
+		if current.Admin != u.Admin {
+			p.add(SecurityChange{Action: ActionUpdateUser, User: User{Username: u.Username, Admin: u.Admin}})
+		}
+	}
+
+	roles, err := r.Security.ListRolesContext(ctx)
+	if err != nil {
+		return p, err
+	}
+
+	existing := make(map[string]Role, len(roles))
+	for _, role := range roles {
+		existing[role.Name] = role
+	}
+
+	managed := make(map[string]bool, len(desired.Roles))
+
+	for _, role := range desired.Roles {
+		managed[role.Name] = true
+
+		current, ok := existing[role.Name]
+		if !ok {
+			p.add(SecurityChange{Action: ActionCreateRole, Role: role.Name})
+		}
+
+		add, remove := diffStrings(current.Principals, role.Principals)
+		for _, n := range add {
+			p.add(SecurityChange{Action: ActionAssignPrincipal, Role: role.Name, Principal: n})
+		}
+		for _, n := range remove {
+			p.add(SecurityChange{Action: ActionUnassignPrincipal, Role: role.Name, Principal: n})
+		}
+
+		var granted []Permission
+		if ok {
+			granted, err = r.Security.ListPermissionsContext(ctx, role.Name)
+			if err != nil {
+				return p, err
+			}
+		}
+
+		grant, revoke := diffPermissions(granted, role.permissions())
+		for _, g := range grant {
+			p.add(SecurityChange{Action: ActionGrantPermission, Role: role.Name, Permission: g})
+		}
+		for _, g := range revoke {
+			p.add(SecurityChange{Action: ActionRevokePermission, Role: role.Name, Permission: g})
+		}
+	}
+
+	if r.Prune {
+		for _, role := range roles {
+			if !managed[role.Name] {
+				p.add(SecurityChange{Action: ActionDeleteRole, Role: role.Name})
+			}
+		}
+	}
+
+	p.sort()
+
+	return p, nil
+}
+
+//Apply executes the changes of a plan in order
+// it stops at the first change that fails
+func (r *SecurityReconciler) Apply(p SecurityPlan) error {
+	return r.ApplyContext(context.Background(), p)
+}
+
+//ApplyContext is Apply with a context that is attached to every request it makes
+func (r *SecurityReconciler) ApplyContext(ctx context.Context, p SecurityPlan) error {
+	for _, c := range p.Changes {
+		if err := r.apply(ctx, c); err != nil {
+			return &SecurityApplyError{Change: c, Err: err}
+		}
+	}
+
+	return nil
+}
+
+//SecurityApplyError is returned by Apply when a change of the plan fails
+// Err is the error the change failed with, the Is functions look through to it
+type SecurityApplyError struct {
+	Change SecurityChange
+	Err    error
+}
+
+func (e *SecurityApplyError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Change, e.Err)
+}
+
+//Cause returns the error the change failed with
+func (e *SecurityApplyError) Cause() error {
+	return e.Err
+}
+
+//Unwrap returns the error the change failed with, for use with errors.Is and errors.As
+func (e *SecurityApplyError) Unwrap() error {
+	return e.Err
+}
+
+//Empty returns true when the server is already in the desired state
+func (p SecurityPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+//String renders the plan one change per line
+func (p SecurityPlan) String() string {
+	if p.Empty() {
+		return "no changes\n"
+	}
+
+	var b bytes.Buffer
+	for _, c := range p.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func (c SecurityChange) String() string {
+	switch c.Action {
+	case ActionCreateUser:
+		return fmt.Sprintf("+ create user %s (admin: %t)", c.User.Username, c.User.Admin)
+	case ActionUpdateUser:
+		return fmt.Sprintf("~ update user %s (admin: %t)", c.User.Username, c.User.Admin)
+	case ActionCreateRole:
+		return "+ create role " + c.Role
+	case ActionAssignPrincipal:
+		return fmt.Sprintf("+ assign %s to role %s", c.Principal, c.Role)
+	case ActionUnassignPrincipal:
+		return fmt.Sprintf("- unassign %s from role %s", c.Principal, c.Role)
+	case ActionGrantPermission:
+		return fmt.Sprintf("+ grant %s to role %s", c.Permission.describe(), c.Role)
+	case ActionRevokePermission:
+		return fmt.Sprintf("- revoke %s from role %s", c.Permission.describe(), c.Role)
+	case ActionDeleteRole:
+		return "- delete role " + c.Role
+	}
+
+	return string(c.Action)
+}
+
+//private functions
+
+var securityActionOrder = map[SecurityAction]int{
+	ActionCreateUser:        0,
+	ActionUpdateUser:        1,
+	ActionCreateRole:        2,
+	ActionAssignPrincipal:   3,
+	ActionUnassignPrincipal: 4,
+	ActionGrantPermission:   5,
+	ActionRevokePermission:  6,
+	ActionDeleteRole:        7,
+}
+
+func (p *SecurityPlan) add(c SecurityChange) {
+	p.Changes = append(p.Changes, c)
+}
+
+//sort puts the changes in the order they can be applied in, users and roles exist before they are used
+func (p *SecurityPlan) sort() {
+	sort.Stable(byAction(p.Changes))
+}
+
+type byAction []SecurityChange
+
+func (b byAction) Len() int      { return len(b) }
+func (b byAction) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byAction) Less(i, j int) bool {
+	return securityActionOrder[b[i].Action] < securityActionOrder[b[j].Action]
+}
+
+func (r *SecurityReconciler) apply(ctx context.Context, c SecurityChange) error {
+	switch c.Action {
+	case ActionCreateUser:
+		if _, err := r.Security.CreateUserContext(ctx, c.User.Username, c.User.Admin); err != nil {
+			return err
+		}
+		if c.User.Password != "" {
+			return r.Security.SetPasswordForUserContext(ctx, c.User.Username, c.User.Password)
+		}
+		return nil
+	case ActionUpdateUser:
+		_, err := r.Security.UpdateUserContext(ctx, c.User)
+		return err
+	case ActionCreateRole:
+		return r.Security.CreateRoleContext(ctx, c.Role)
+	case ActionAssignPrincipal:
+		return r.Security.AssignPrincipalsContext(ctx, c.Role, c.Principal)
+	case ActionUnassignPrincipal:
+		return r.Security.UnassignPrincipalsContext(ctx, c.Role, c.Principal)
+	case ActionGrantPermission:
+		return r.Security.GrantPermissionContext(ctx, c.Permission)
+	case ActionRevokePermission:
+		return r.Security.RevokePermissionContext(ctx, c.Permission)
+	case ActionDeleteRole:
+		return r.Security.DeleteRoleContext(ctx, c.Role)
+	}
+
+	return fmt.Errorf("unknown security action %s", c.Action)
+}
+
+//permissions lists the global and ci permissions of the role
+func (r RoleState) permissions() []Permission {
+	var p []Permission
+
+	for _, n := range r.Permissions {
+		p = append(p, Permission{Role: r.Name, Permission: n})
+	}
+
+	for id, names := range r.CiPermissions {
+		for _, n := range names {
+			p = append(p, Permission{Role: r.Name, Permission: n, CiID: id})
+		}
+	}
+
+	return p
+}
+
+func (p Permission) describe() string {
+	if p.CiID == "" {
+		return p.Permission
+	}
+
+	return p.Permission + " on " + p.CiID
+}
+
+//diffStrings returns the entries of desired missing in current and the entries of current missing in desired
+func diffStrings(current, desired []string) (add, remove []string) {
+	have := make(map[string]bool, len(current))
+	for _, s := range current {
+		have[s] = true
+	}
+
+	want := make(map[string]bool, len(desired))
+	for _, s := range desired {
+		want[s] = true
+		if !have[s] {
+			add = append(add, s)
+		}
+	}
+
+	for _, s := range current {
+		if !want[s] {
+			remove = append(remove, s)
+		}
+	}
+
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	return add, remove
+}
+
+func diffPermissions(current, desired []Permission) (grant, revoke []Permission) {
+	have := make(map[string]bool, len(current))
+	for _, p := range current {
+		have[p.key()] = true
+	}
+
+	want := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		want[p.key()] = true
+		if !have[p.key()] {
+			grant = append(grant, p)
+		}
+	}
+
+	for _, p := range current {
+		if !want[p.key()] {
+			revoke = append(revoke, p)
+		}
+	}
+
+	sort.Sort(byPermission(grant))
+	sort.Sort(byPermission(revoke))
+
+	return grant, revoke
+}
+
+func (p Permission) key() string {
+	return p.CiID + "\x00" + p.Permission
+}
+
+type byPermission []Permission
+
+func (b byPermission) Len() int           { return len(b) }
+func (b byPermission) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPermission) Less(i, j int) bool { return b[i].key() < b[j].key() }
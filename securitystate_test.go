@@ -0,0 +1,168 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecurityReconciler(t *testing.T) {
+	setup()
+	defer teardown()
+
+	// everything but the reads the plan is made from is recorded
+	var requests []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	}
+
+	mux.HandleFunc("/", record)
+	mux.HandleFunc("/deployit/security/user/jenkins", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			record(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/deployit/security/user/ops", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			record(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"username": "ops", "admin": true}`)
+	})
+	mux.HandleFunc("/deployit/security/role/principals", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestRolePrincipalsResponse)
+	})
+	mux.HandleFunc("/deployit/security/granted-permissions/role/deployers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"": ["login"], "Environments/dev": ["read", "deploy#initial"]}`)
+	})
+
+	desired, err := LoadSecurityState(strings.NewReader(mockTestSecurityState))
+	if err != nil {
+		t.Fatalf("LoadSecurityState returned error: %v", err)
+	}
+
+	r := NewSecurityReconciler(client.Security)
+	r.Prune = true
+
+	p, err := r.Plan(desired)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expected := `+ create user jenkins (admin: false)
+~ update user ops (admin: false)
++ create role releasers
++ assign jenkins to role releasers
+- unassign ops from role deployers
++ grant deploy#upgrade on Environments/dev to role deployers
++ grant read on Environments/prod to role releasers
+- revoke deploy#initial on Environments/dev from role deployers
+- delete role viewers
+`
+
+	if p.String() != expected {
+		t.Errorf("Plan returned\n%v\nexpected\n%v", p, expected)
+	}
+
+	if err := r.Apply(p); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	expectedRequests := []string{
+		"POST /deployit/security/user/jenkins",
+		"PUT /deployit/security/user/ops",
+		"PUT /deployit/security/role/releasers",
+		"PUT /deployit/security/role/releasers/jenkins",
+		"DELETE /deployit/security/role/deployers/ops",
+		"PUT /deployit/security/permission/deploy#upgrade/deployers/Environments/dev",
+		"PUT /deployit/security/permission/read/releasers/Environments/prod",
+		"DELETE /deployit/security/permission/deploy#initial/deployers/Environments/dev",
+		"DELETE /deployit/security/role/viewers",
+	}
+
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Apply sent\n%v\nexpected\n%v", strings.Join(requests, "\n"), strings.Join(expectedRequests, "\n"))
+	}
+}
+
+func TestSecurityReconcilerApplyError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/security/role/releasers", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+
+	p := SecurityPlan{Changes: []SecurityChange{{Action: ActionCreateRole, Role: "releasers"}}}
+
+	err := NewSecurityReconciler(client.Security).Apply(p)
+	if !IsUnauthorized(err) {
+		t.Fatalf("Expected an unauthorized error but got %v", err)
+	}
+
+	e, ok := err.(*SecurityApplyError)
+	if !ok {
+		t.Fatalf("Expected a SecurityApplyError but got %T", err)
+	}
+
+	if e.Change.Role != "releasers" {
+		t.Errorf("SecurityApplyError holds change %v, expected the create of role releasers", e.Change)
+	}
+}
+
+func TestLoadSecurityStateYAML(t *testing.T) {
+	j, err := LoadSecurityState(strings.NewReader(mockTestSecurityState))
+	if err != nil {
+		t.Fatalf("LoadSecurityState returned error for json: %v", err)
+	}
+
+	y, err := LoadSecurityState(strings.NewReader(mockTestSecurityStateYAML))
+	if err != nil {
+		t.Fatalf("LoadSecurityState returned error for yaml: %v", err)
+	}
+
+	if !reflect.DeepEqual(j, y) {
+		t.Errorf("yaml state %+v differs from json state %+v", y, j)
+	}
+
+	if _, err := LoadSecurityState(strings.NewReader("roles: [")); err == nil {
+		t.Errorf("Expected an error for invalid yaml")
+	}
+}
+
+var mockTestSecurityStateYAML = `
+users:
+  - username: jenkins
+    admin: false
+  - username: ops
+roles:
+  - name: deployers
+    principals: [jenkins]
+    permissions: [login]
+    ciPermissions:
+      Environments/dev: [read, deploy#upgrade]
+  - name: releasers
+    principals:
+      - jenkins
+    ciPermissions:
+      Environments/prod: [read]
+`
+
+var mockTestSecurityState = `{
+  "users": [
+    {"username": "jenkins", "admin": false},
+    {"username": "ops", "admin": false}
+  ],
+  "roles": [
+    {"name": "deployers", "principals": ["jenkins"], "permissions": ["login"],
+     "ciPermissions": {"Environments/dev": ["read", "deploy#upgrade"]}},
+    {"name": "releasers", "principals": ["jenkins"],
+     "ciPermissions": {"Environments/prod": ["read"]}}
+  ]
+}`
@@ -0,0 +1,183 @@
+package xld
+
+import (
+	"context"
+	"io/ioutil"
+)
+
+const (
+	serverBasePath = "deployit/server"
+)
+
+//Server modes as reported by State
+const (
+	ServerModeDefault     = "DEFAULT"
+	ServerModeMaintenance = "MAINTENANCE"
+)
+
+//ServerService represents the service for administering the xldeploy server
+type ServerService interface {
+	Info() (ServerInfo, error)
+	InfoContext(ctx context.Context) (ServerInfo, error)
+	State() (ServerState, error)
+	StateContext(ctx context.Context) (ServerState, error)
+	EnterMaintenance() error
+	EnterMaintenanceContext(ctx context.Context) error
+	LeaveMaintenance() error
+	LeaveMaintenanceContext(ctx context.Context) error
+	RunGarbageCollector() error
+	RunGarbageCollectorContext(ctx context.Context) error
+	ReloadPlugins() error
+	ReloadPluginsContext(ctx context.Context) error
+	Shutdown() error
+	ShutdownContext(ctx context.Context) error
+}
+
+//ServerServiceOp holds the communication service for the server rest api
+type ServerServiceOp struct {
+	client *Client
+}
+
+var _ ServerService = &ServerServiceOp{}
+
+//ServerInfo describes the version of xldeploy and what is installed on it
+type ServerInfo struct {
+	Version          string       `json:"version"`
+	Plugins          []PluginInfo `json:"plugins"`
+	ClasspathEntries []string     `json:"classpathEntries"`
+}
+
+//PluginInfo is a plugin installed on the xldeploy server
+type PluginInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+//ServerState tells the mode the xldeploy server runs in
+type ServerState struct {
+	Mode string `json:"current-mode"`
+}
+
+//Info returns the version, plugins and classpath of the xldeploy server
+func (s ServerServiceOp) Info() (ServerInfo, error) {
+	return s.InfoContext(context.Background())
+}
+
+//InfoContext is Info with a context that is attached to every request it makes
+func (s ServerServiceOp) InfoContext(ctx context.Context) (ServerInfo, error) {
+	var i ServerInfo
+
+	req, err := s.client.NewRequestContext(ctx, serverBasePath+"/info", "GET", nil)
+	if err != nil {
+		return i, err
+	}
+
+	_, err = s.client.Do(req, &i)
+
+	return i, err
+}
+
+//Plugin returns the plugin with name n and whether it is installed
+func (i ServerInfo) Plugin(n string) (PluginInfo, bool) {
+	for _, p := range i.Plugins {
+		if p.Name == n {
+			return p, true
+		}
+	}
+
+	return PluginInfo{}, false
+}
+
+//State returns the mode the xldeploy server runs in
+func (s ServerServiceOp) State() (ServerState, error) {
+	return s.StateContext(context.Background())
+}
+
+//StateContext is State with a context that is attached to every request it makes
+func (s ServerServiceOp) StateContext(ctx context.Context) (ServerState, error) {
+	var st ServerState
+
+	req, err := s.client.NewRequestContext(ctx, serverBasePath+"/state", "GET", nil)
+	if err != nil {
+		return st, err
+	}
+
+	_, err = s.client.Do(req, &st)
+
+	return st, err
+}
+
+//InMaintenance returns true when the server only accepts requests from admins
+func (st ServerState) InMaintenance() bool {
+	return st.Mode == ServerModeMaintenance
+}
+
+//EnterMaintenance puts the server in maintenance mode, no new tasks can be started until it is left
+func (s ServerServiceOp) EnterMaintenance() error {
+	return s.EnterMaintenanceContext(context.Background())
+}
+
+//EnterMaintenanceContext is EnterMaintenance with a context that is attached to every request it makes
+func (s ServerServiceOp) EnterMaintenanceContext(ctx context.Context) error {
+	return s.action(ctx, "/maintenance/start")
+}
+
+//LeaveMaintenance returns the server to its default mode
+func (s ServerServiceOp) LeaveMaintenance() error {
+	return s.LeaveMaintenanceContext(context.Background())
+}
+
+//LeaveMaintenanceContext is LeaveMaintenance with a context that is attached to every request it makes
+func (s ServerServiceOp) LeaveMaintenanceContext(ctx context.Context) error {
+	return s.action(ctx, "/maintenance/stop")
+}
+
+//RunGarbageCollector runs the garbage collector of the repository
+func (s ServerServiceOp) RunGarbageCollector() error {
+	return s.RunGarbageCollectorContext(context.Background())
+}
+
+//RunGarbageCollectorContext is RunGarbageCollector with a context that is attached to every request it makes
+func (s ServerServiceOp) RunGarbageCollectorContext(ctx context.Context) error {
+	return s.action(ctx, "/gc")
+}
+
+//ReloadPlugins makes the server reload its plugins and type system
+// cached metadata is dropped as well, the type system may have changed
+func (s ServerServiceOp) ReloadPlugins() error {
+	return s.ReloadPluginsContext(context.Background())
+}
+
+//ReloadPluginsContext is ReloadPlugins with a context that is attached to every request it makes
+func (s ServerServiceOp) ReloadPluginsContext(ctx context.Context) error {
+	if err := s.action(ctx, "/reload"); err != nil {
+		return err
+	}
+
+	s.client.Meta.InvalidateAll()
+
+	return nil
+}
+
+//Shutdown stops the xldeploy server
+func (s ServerServiceOp) Shutdown() error {
+	return s.ShutdownContext(context.Background())
+}
+
+//ShutdownContext is Shutdown with a context that is attached to every request it makes
+func (s ServerServiceOp) ShutdownContext(ctx context.Context) error {
+	return s.action(ctx, "/shutdown")
+}
+
+//private functions
+
+func (s ServerServiceOp) action(ctx context.Context, path string) error {
+	req, err := s.client.NewRequestContext(ctx, serverBasePath+path, "POST", nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Do(req, ioutil.Discard)
+
+	return err
+}
@@ -0,0 +1,122 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestServerInfo(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/server/info", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, mockTestServerInfoResponse)
+	})
+
+	i, err := client.Server.Info()
+	if err != nil {
+		t.Fatalf("Info returned error: %v", err)
+	}
+
+	want := ServerInfo{
+		Version: "6.0.1",
+		Plugins: []PluginInfo{
+			{Name: "base-plugin", Version: "6.0.1"},
+			{Name: "tomcat-plugin", Version: "6.0.0"},
+		},
+		ClasspathEntries: []string{"conf", "ext", "lib/appserver-core-6.0.1.jar"},
+	}
+
+	if !reflect.DeepEqual(i, want) {
+		t.Errorf("Info returned %+v, want %+v", i, want)
+	}
+
+	p, ok := i.Plugin("tomcat-plugin")
+	if !ok || p.Version != "6.0.0" {
+		t.Errorf("Plugin returned %+v, %v", p, ok)
+	}
+
+	if _, ok := i.Plugin("was-plugin"); ok {
+		t.Errorf("Plugin found a plugin that is not installed")
+	}
+
+	if !client.VerifyConnection() {
+		t.Errorf("VerifyConnection returned false")
+	}
+}
+
+func TestVerifyConnectionFails(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/server/info", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	if client.VerifyConnection() {
+		t.Errorf("VerifyConnection returned true")
+	}
+}
+
+func TestServerState(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/server/state", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"current-mode": "MAINTENANCE"}`)
+	})
+
+	s, err := client.Server.State()
+	if err != nil {
+		t.Fatalf("State returned error: %v", err)
+	}
+
+	if s.Mode != ServerModeMaintenance || !s.InMaintenance() {
+		t.Errorf("State returned %+v", s)
+	}
+}
+
+func TestServerActions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var called []string
+	for _, p := range []string{"maintenance/start", "maintenance/stop", "gc", "reload", "shutdown"} {
+		p := p
+		mux.HandleFunc("/deployit/server/"+p, func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "POST")
+			called = append(called, p)
+		})
+	}
+
+	actions := []func() error{
+		client.Server.EnterMaintenance,
+		client.Server.LeaveMaintenance,
+		client.Server.RunGarbageCollector,
+		client.Server.ReloadPlugins,
+		client.Server.Shutdown,
+	}
+	for _, a := range actions {
+		if err := a(); err != nil {
+			t.Fatalf("server action returned error: %v", err)
+		}
+	}
+
+	want := []string{"maintenance/start", "maintenance/stop", "gc", "reload", "shutdown"}
+	if !reflect.DeepEqual(called, want) {
+		t.Errorf("server actions called %v, want %v", called, want)
+	}
+}
+
+var mockTestServerInfoResponse = `{
+  "version": "6.0.1",
+  "plugins": [
+    {"name": "base-plugin", "version": "6.0.1"},
+    {"name": "tomcat-plugin", "version": "6.0.0"}
+  ],
+  "classpathEntries": ["conf", "ext", "lib/appserver-core-6.0.1.jar"]
+}`
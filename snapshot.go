@@ -0,0 +1,232 @@
+package xld
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+//Snapshot is a portable copy of a repository subtree
+// Root is the id the subtree was exported from, Cis hold root itself and every ci below it
+type Snapshot struct {
+	Root string `json:"root"`
+	Cis  []Ci   `json:"cis"`
+}
+
+//ExportOptions controls what ExportTree puts in a snapshot
+type ExportOptions struct {
+	// RedactPasswords leaves the values of password properties out of the snapshot
+	RedactPasswords bool
+}
+
+//ImportOptions controls how ImportTree recreates the cis of a snapshot
+type ImportOptions struct {
+	// From and To rewrite the ids of the cis and the references between them,
+	// every id that is From or starts with From/ gets To instead of From
+	From string
+	To   string
+	// SkipExisting leaves cis that already exist untouched instead of overwriting them
+	SkipExisting bool
+}
+
+//ReadSnapshot reads a json or yaml snapshot, the format is detected from the content
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	var s Snapshot
+
+	err := decodeJSONOrYAML(r, &s)
+
+	return s, err
+}
+
+//WriteSnapshot writes s as indented json
+func WriteSnapshot(w io.Writer, s Snapshot) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+
+	return err
+}
+
+//WriteSnapshotYAML writes s as yaml, the cis keep the flat layout they have in json
+func WriteSnapshotYAML(w io.Writer, s Snapshot) error {
+	return encodeYAML(w, s)
+}
+
+//ExportTree reads root and all cis below it into a snapshot
+// only the properties of the type metadata are kept, the repository metadata of the cis
+// ($token, $createdBy, ...) and other attributes the server adds are left out
+func (r RepositoryServiceOp) ExportTree(root string, o ExportOptions) (Snapshot, error) {
+	return r.ExportTreeContext(context.Background(), root, o)
+}
+
+//ExportTreeContext is ExportTree with a context that is attached to every request it makes
+func (r RepositoryServiceOp) ExportTreeContext(ctx context.Context, root string, o ExportOptions) (Snapshot, error) {
+	s := Snapshot{Root: root}
+
+	var ids []string
+
+	// the repository roots like Environments are not cis themselves
+	if parentID(root) != "" {
+		ids = append(ids, root)
+	}
+
+	it := r.QueryAllContext(ctx, QueryOptions{Ancestor: root})
+	for it.Next() {
+		ids = append(ids, it.Entry().ID)
+	}
+	if it.Err() != nil {
+		return s, it.Err()
+	}
+
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > defaultResultsPerPage {
+			n = defaultResultsPerPage
+		}
+
+		cis, err := r.GetCisContext(ctx, ids[:n]...)
+		if err != nil {
+			return s, err
+		}
+		ids = ids[n:]
+
+		for _, c := range cis {
+			p, err := r.exportProperties(ctx, c, o)
+			if err != nil {
+				return s, err
+			}
+
+			s.Cis = append(s.Cis, Ci{ID: c.ID, Type: c.Type, Properties: p})
+		}
+	}
+
+	return s, nil
+}
+
+//ImportTree recreates the cis of a snapshot, parents and referenced cis first
+// it returns the cis as xldeploy saved them
+func (r RepositoryServiceOp) ImportTree(s Snapshot, o ImportOptions) ([]Ci, error) {
+	return r.ImportTreeContext(context.Background(), s, o)
+}
+
+//ImportTreeContext is ImportTree with a context that is attached to every request it makes
+func (r RepositoryServiceOp) ImportTreeContext(ctx context.Context, s Snapshot, o ImportOptions) ([]Ci, error) {
+	var saved []Ci
+
+	cis := make([]Ci, len(s.Cis))
+	for i, c := range s.Cis {
+		meta, err := r.client.Meta.GetTypeContext(ctx, c.Type)
+		if err != nil {
+			return saved, err
+		}
+
+		kinds := make(map[string]string, len(meta.Properties))
+		for _, p := range meta.Properties {
+			kinds[p.Name] = p.Kind
+		}
+
+		cis[i] = Ci{ID: o.rewrite(c.ID), Type: c.Type, Properties: make(map[string]interface{}, len(c.Properties))}
+		for k, v := range c.Properties {
+			switch kinds[k] {
+			case KindCi, KindSetOfCi, KindListOfCi:
+				v = o.rewriteValue(v)
+			}
+			cis[i].Properties[k] = v
+		}
+	}
+
+	ordered, err := applyOrder(cis)
+	if err != nil {
+		return saved, err
+	}
+
+	for _, c := range ordered {
+		if o.SkipExisting {
+			exists, err := r.CiExistsContext(ctx, c.ID)
+			if err != nil {
+				return saved, err
+			}
+			if exists {
+				continue
+			}
+		}
+
+		sc, err := r.CreateCiContext(ctx, c.ID, c.Type, c.Properties)
+		if err != nil {
+			return saved, err
+		}
+
+		saved = append(saved, sc)
+	}
+
+	return saved, nil
+}
+
+//private functions
+
+//exportProperties returns the properties of c that are part of its type, like GetCi does,
+// so the snapshot can be imported again
+func (r RepositoryServiceOp) exportProperties(ctx context.Context, c Ci, o ExportOptions) (map[string]interface{}, error) {
+	meta, err := r.client.Meta.GetTypeContext(ctx, c.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	p := make(map[string]interface{}, len(meta.Properties))
+	for _, prop := range meta.Properties {
+		v, ok := c.Properties[prop.Name]
+		if !ok || (prop.Password && o.RedactPasswords) {
+			continue
+		}
+		p[prop.Name] = v
+	}
+
+	return p, nil
+}
+
+func (o ImportOptions) rewrite(id string) string {
+	if o.From == "" || o.From == o.To {
+		return id
+	}
+
+	if id == o.From || strings.HasPrefix(id, o.From+"/") {
+		return o.To + id[len(o.From):]
+	}
+
+	return id
+}
+
+//rewriteValue rewrites the ci references in the value of a CI, SET_OF_CI or LIST_OF_CI property
+func (o ImportOptions) rewriteValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return o.rewrite(v)
+	case []string:
+		l := make([]string, len(v))
+		for i, e := range v {
+			l[i] = o.rewrite(e)
+		}
+		return l
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = o.rewriteValue(e)
+		}
+		return l
+	case map[string]interface{}:
+		if id, ok := v["id"].(string); ok {
+			m := make(map[string]interface{}, len(v))
+			for k, e := range v {
+				m[k] = e
+			}
+			m["id"] = o.rewrite(id)
+			return m
+		}
+	}
+
+	return v
+}
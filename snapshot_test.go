@@ -0,0 +1,207 @@
+package xld
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportTree(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ancestor") != "Environments/Team-A" {
+			t.Errorf("Expected a query under Environments/Team-A but got %v", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"ref": "Environments/Team-A/dev", "type": "udm.Environment"},
+			{"ref": "Environments/Team-A/dict", "type": "udm.Dictionary"}]`)
+	})
+	mux.HandleFunc("/deployit/repository/cis/read", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var ids []string
+		json.NewDecoder(r.Body).Decode(&ids)
+
+		expected := []string{"Environments/Team-A", "Environments/Team-A/dev", "Environments/Team-A/dict"}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v to be read but got %v", expected, ids)
+		}
+
+		fmt.Fprint(w, mockTestTeamACis)
+	})
+	mux.HandleFunc("/deployit/metadata/type/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTeamAMeta[strings.TrimPrefix(r.URL.Path, "/deployit/metadata/type/")])
+	})
+
+	s, err := client.Repository.ExportTree("Environments/Team-A", ExportOptions{RedactPasswords: true})
+	if err != nil {
+		t.Fatalf("ExportTree returned error: %v", err)
+	}
+
+	if len(s.Cis) != 3 {
+		t.Fatalf("Expected 3 cis in the snapshot but got %v", s.Cis)
+	}
+
+	dict := s.Cis[2]
+	if _, ok := dict.Properties["secret"]; ok {
+		t.Errorf("Expected the password property to be redacted but got %v", dict.Properties)
+	}
+
+	if dict.Token != "" || dict.Properties["entries"] == nil {
+		t.Errorf("Expected the dictionary without token but with its entries, got %+v", dict)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, s); err != nil {
+		t.Fatalf("WriteSnapshot returned error: %v", err)
+	}
+
+	rs, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("ReadSnapshot returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(rs, s) {
+		t.Errorf("Expected the snapshot to survive a write and read, got %+v, expected %+v", rs, s)
+	}
+
+	buf.Reset()
+	if err := WriteSnapshotYAML(&buf, s); err != nil {
+		t.Fatalf("WriteSnapshotYAML returned error: %v", err)
+	}
+
+	// the cis are flat like in json, the properties are not nested
+	if y := buf.String(); !strings.Contains(y, "- entries:\n    db.url: jdbc:h2:mem\n  id: Environments/Team-A/dict\n") || strings.Contains(y, "properties:") {
+		t.Errorf("Expected flat cis in the yaml snapshot but got\n%s", y)
+	}
+
+	ys, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatalf("ReadSnapshot returned error for yaml: %v", err)
+	}
+
+	if !reflect.DeepEqual(ys, s) {
+		t.Errorf("Expected the snapshot to survive a yaml write and read, got %+v, expected %+v", ys, s)
+	}
+}
+
+func TestImportTree(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTeamAMeta[strings.TrimPrefix(r.URL.Path, "/deployit/metadata/type/")])
+	})
+	mux.HandleFunc("/deployit/repository/exists/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"boolean": false}`)
+	})
+
+	var created []string
+	mux.HandleFunc("/deployit/repository/ci/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var c Ci
+		json.NewDecoder(r.Body).Decode(&c)
+		created = append(created, c.ID)
+
+		if c.ID == "Environments/Team-B/dev" {
+			if !reflect.DeepEqual(c.Properties["dictionaries"], []interface{}{"Environments/Team-B/dict"}) {
+				t.Errorf("Expected the dictionary reference to be rewritten but got %v", c.Properties["dictionaries"])
+			}
+			if !reflect.DeepEqual(c.Properties["members"], []interface{}{"Infrastructure/web1"}) {
+				t.Errorf("Expected the members outside the tree to be kept but got %v", c.Properties["members"])
+			}
+		}
+
+		json.NewEncoder(w).Encode(c)
+	})
+
+	var s Snapshot
+	s.Root = "Environments/Team-A"
+	if err := json.Unmarshal([]byte(mockTestTeamACis), &s.Cis); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Repository.ImportTree(s, ImportOptions{From: "Environments/Team-A", To: "Environments/Team-B"})
+	if err != nil {
+		t.Fatalf("ImportTree returned error: %v", err)
+	}
+
+	expected := []string{"Environments/Team-B", "Environments/Team-B/dict", "Environments/Team-B/dev"}
+	if !reflect.DeepEqual(created, expected) {
+		t.Errorf("Expected %v to be created in order but got %v", expected, created)
+	}
+}
+
+func TestExportImportTree(t *testing.T) {
+	setup()
+	defer teardown()
+
+	// the server adds attributes that are not properties of the type
+	mux.HandleFunc("/deployit/repository/query", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"ref": "Environments/Team-A/dev", "type": "udm.Environment"},
+			{"ref": "Environments/Team-A/dict", "type": "udm.Dictionary"}]`)
+	})
+	mux.HandleFunc("/deployit/repository/cis/read", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Replace(mockTestTeamACis, `"$token": "t3",`, `"$token": "t3", "$internalId": 3, "$ciAttributes": {"scmTraceabilityData": null},`, 1))
+	})
+	mux.HandleFunc("/deployit/metadata/type/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTeamAMeta[strings.TrimPrefix(r.URL.Path, "/deployit/metadata/type/")])
+	})
+	mux.HandleFunc("/deployit/repository/exists/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"boolean": false}`)
+	})
+
+	var created []Ci
+	mux.HandleFunc("/deployit/repository/ci/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var c Ci
+		json.NewDecoder(r.Body).Decode(&c)
+		created = append(created, c)
+
+		json.NewEncoder(w).Encode(c)
+	})
+
+	s, err := client.Repository.ExportTree("Environments/Team-A", ExportOptions{})
+	if err != nil {
+		t.Fatalf("ExportTree returned error: %v", err)
+	}
+
+	for _, c := range s.Cis {
+		for _, n := range []string{"$internalId", "$ciAttributes"} {
+			if _, ok := c.Properties[n]; ok {
+				t.Errorf("Expected %v to be left out of %v but got %v", n, c.ID, c.Properties)
+			}
+		}
+	}
+
+	if _, err := client.Repository.ImportTree(s, ImportOptions{From: "Environments/Team-A", To: "Environments/Team-B"}); err != nil {
+		t.Fatalf("ImportTree returned error: %v", err)
+	}
+
+	if len(created) != 3 || created[1].Properties["secret"] != "{b64}xyz" {
+		t.Errorf("Expected the cis to be created with their properties but got %+v", created)
+	}
+}
+
+var mockTestTeamACis = `[
+  {"id": "Environments/Team-A", "type": "core.Directory", "$token": "t1"},
+  {"id": "Environments/Team-A/dev", "type": "udm.Environment", "$token": "t2",
+   "members": ["Infrastructure/web1"], "dictionaries": ["Environments/Team-A/dict"]},
+  {"id": "Environments/Team-A/dict", "type": "udm.Dictionary", "$token": "t3",
+   "entries": {"db.url": "jdbc:h2:mem"}, "secret": "{b64}xyz"}
+]`
+
+var mockTestTeamAMeta = map[string]string{
+	"core.Directory":  `{"type": "core.Directory", "properties": []}`,
+	"udm.Environment": mockTestEnvironmentMetaResponse,
+	"udm.Dictionary": `{"type": "udm.Dictionary", "properties": [
+		{"name": "entries", "kind": "MAP_STRING_STRING"},
+		{"name": "secret", "kind": "STRING", "password": true}]}`,
+}
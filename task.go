@@ -0,0 +1,333 @@
+package xld
+
+import (
+	"context"
+	"io/ioutil"
+)
+
+const (
+	taskBasePath = "deployit/tasks/v2"
+)
+
+//TaskState is the state of a task or of one of its blocks
+type TaskState string
+
+//States a task (or block) can be in
+const (
+	TaskPending    TaskState = "PENDING"
+	TaskQueued     TaskState = "QUEUED"
+	TaskScheduled  TaskState = "SCHEDULED"
+	TaskExecuting  TaskState = "EXECUTING"
+	TaskExecuted   TaskState = "EXECUTED"
+	TaskStopping   TaskState = "STOPPING"
+	TaskStopped    TaskState = "STOPPED"
+	TaskFailing    TaskState = "FAILING"
+	TaskFailed     TaskState = "FAILED"
+	TaskAborting   TaskState = "ABORTING"
+	TaskAborted    TaskState = "ABORTED"
+	TaskCancelling TaskState = "CANCELLING"
+	TaskCancelled  TaskState = "CANCELLED"
+	TaskDone       TaskState = "DONE"
+)
+
+//StepState is the state of a single step in a task
+type StepState string
+
+//States a step can be in
+const (
+	StepPending   StepState = "PENDING"
+	StepSkip      StepState = "SKIP"
+	StepExecuting StepState = "EXECUTING"
+	StepPaused    StepState = "PAUSED"
+	StepFailed    StepState = "FAILED"
+	StepDone      StepState = "DONE"
+	StepSkipped   StepState = "SKIPPED"
+)
+
+//TaskService represents the service for engaging the XL-Deploy task rest interface
+type TaskService interface {
+	Start(id string) error
+	StartContext(ctx context.Context, id string) error
+	Get(id string) (Task, error)
+	GetContext(ctx context.Context, id string) (Task, error)
+	Cancel(id string) error
+	CancelContext(ctx context.Context, id string) error
+	Abort(id string) error
+	AbortContext(ctx context.Context, id string) error
+	Stop(id string) error
+	StopContext(ctx context.Context, id string) error
+	Archive(id string) error
+	ArchiveContext(ctx context.Context, id string) error
+	Skip(id string, steps ...string) (Task, error)
+	SkipContext(ctx context.Context, id string, steps ...string) (Task, error)
+	Unskip(id string, steps ...string) (Task, error)
+	UnskipContext(ctx context.Context, id string, steps ...string) (Task, error)
+	ListCurrent() ([]Task, error)
+	ListCurrentContext(ctx context.Context) ([]Task, error)
+	GetStep(id, blockPath, stepPath string) (Step, error)
+	GetStepContext(ctx context.Context, id, blockPath, stepPath string) (Step, error)
+	GetBlockSteps(id, blockPath string) (Block, error)
+	GetBlockStepsContext(ctx context.Context, id, blockPath string) (Block, error)
+	WaitForTask(id string, o *WaitOptions) (Task, error)
+	WaitForTaskContext(ctx context.Context, id string, o *WaitOptions) (Task, error)
+	StartAndWait(id string, o *WaitOptions) (Task, error)
+	StartAndWaitContext(ctx context.Context, id string, o *WaitOptions) (Task, error)
+}
+
+//TaskServiceOp holds the communication service for the Task rest api
+type TaskServiceOp struct {
+	client *Client
+}
+
+var _ TaskService = &TaskServiceOp{}
+
+//Task is a xldeploy task together with its block tree
+type Task struct {
+	ID             string            `json:"id"`
+	Description    string            `json:"description"`
+	State          TaskState         `json:"state"`
+	Owner          string            `json:"owner,omitempty"`
+	StartDate      string            `json:"startDate,omitempty"`
+	CompletionDate string            `json:"completionDate,omitempty"`
+	ScheduledDate  string            `json:"scheduledDate,omitempty"`
+	Failures       int               `json:"failures"`
+	ActiveBlocks   []string          `json:"activeBlocks,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Block          Block             `json:"block"`
+}
+
+//Block is a phase or group of steps in a task
+// composite blocks hold other blocks, step blocks hold steps
+type Block struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	State       TaskState `json:"state"`
+	Blocks      []Block   `json:"blocks,omitempty"`
+	Steps       []Step    `json:"steps,omitempty"`
+}
+
+//Step is a single unit of work in a task
+type Step struct {
+	Description    string            `json:"description"`
+	State          StepState         `json:"state"`
+	Log            string            `json:"log,omitempty"`
+	StartDate      string            `json:"startDate,omitempty"`
+	CompletionDate string            `json:"completionDate,omitempty"`
+	FailureCount   int               `json:"failureCount"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+//Start starts (or resumes) a task
+func (t TaskServiceOp) Start(id string) error {
+	return t.StartContext(context.Background(), id)
+}
+
+//StartContext is Start with a context that is attached to every request it makes
+func (t TaskServiceOp) StartContext(ctx context.Context, id string) error {
+	return t.action(ctx, id, "start", "POST")
+}
+
+//Get retrieves a task and its block tree
+func (t TaskServiceOp) Get(id string) (Task, error) {
+	return t.GetContext(context.Background(), id)
+}
+
+//GetContext is Get with a context that is attached to every request it makes
+func (t TaskServiceOp) GetContext(ctx context.Context, id string) (Task, error) {
+	var task Task
+
+	url := taskBasePath + "/" + id
+
+	req, err := t.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return task, err
+	}
+
+	_, err = t.client.Do(req, &task)
+
+	return task, err
+}
+
+//Cancel cancels a task that is not running
+func (t TaskServiceOp) Cancel(id string) error {
+	return t.CancelContext(context.Background(), id)
+}
+
+//CancelContext is Cancel with a context that is attached to every request it makes
+func (t TaskServiceOp) CancelContext(ctx context.Context, id string) error {
+	return t.action(ctx, id, "", "DELETE")
+}
+
+//Abort aborts a running task
+func (t TaskServiceOp) Abort(id string) error {
+	return t.AbortContext(context.Background(), id)
+}
+
+//AbortContext is Abort with a context that is attached to every request it makes
+func (t TaskServiceOp) AbortContext(ctx context.Context, id string) error {
+	return t.action(ctx, id, "abort", "POST")
+}
+
+//Stop gracefully stops a running task after the current step
+func (t TaskServiceOp) Stop(id string) error {
+	return t.StopContext(context.Background(), id)
+}
+
+//StopContext is Stop with a context that is attached to every request it makes
+func (t TaskServiceOp) StopContext(ctx context.Context, id string) error {
+	return t.action(ctx, id, "stop", "POST")
+}
+
+//Archive archives an executed task
+func (t TaskServiceOp) Archive(id string) error {
+	return t.ArchiveContext(context.Background(), id)
+}
+
+//ArchiveContext is Archive with a context that is attached to every request it makes
+func (t TaskServiceOp) ArchiveContext(ctx context.Context, id string) error {
+	return t.action(ctx, id, "archive", "POST")
+}
+
+//Skip marks steps of a task to be skipped
+// steps are step paths like 0_1_1
+func (t TaskServiceOp) Skip(id string, steps ...string) (Task, error) {
+	return t.SkipContext(context.Background(), id, steps...)
+}
+
+//SkipContext is Skip with a context that is attached to every request it makes
+func (t TaskServiceOp) SkipContext(ctx context.Context, id string, steps ...string) (Task, error) {
+	return t.steps(ctx, id, "skip", steps)
+}
+
+//Unskip marks skipped steps of a task to be executed again
+// steps are step paths like 0_1_1
+func (t TaskServiceOp) Unskip(id string, steps ...string) (Task, error) {
+	return t.UnskipContext(context.Background(), id, steps...)
+}
+
+//UnskipContext is Unskip with a context that is attached to every request it makes
+func (t TaskServiceOp) UnskipContext(ctx context.Context, id string, steps ...string) (Task, error) {
+	return t.steps(ctx, id, "unskip", steps)
+}
+
+//ListCurrent returns the active tasks of the current user
+func (t TaskServiceOp) ListCurrent() ([]Task, error) {
+	return t.ListCurrentContext(context.Background())
+}
+
+//ListCurrentContext is ListCurrent with a context that is attached to every request it makes
+func (t TaskServiceOp) ListCurrentContext(ctx context.Context) ([]Task, error) {
+	var tasks []Task
+
+	url := taskBasePath + "/current"
+
+	req, err := t.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return tasks, err
+	}
+
+	_, err = t.client.Do(req, &tasks)
+
+	return tasks, err
+}
+
+//GetStep retrieves a single step, including its log
+// blockPath is the id of the step block (0_1), stepPath the index of the step in that block
+func (t TaskServiceOp) GetStep(id, blockPath, stepPath string) (Step, error) {
+	return t.GetStepContext(context.Background(), id, blockPath, stepPath)
+}
+
+//GetStepContext is GetStep with a context that is attached to every request it makes
+func (t TaskServiceOp) GetStepContext(ctx context.Context, id, blockPath, stepPath string) (Step, error) {
+	var s Step
+
+	url := taskBasePath + "/" + id + "/block/" + blockPath + "/step/" + stepPath
+
+	req, err := t.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return s, err
+	}
+
+	_, err = t.client.Do(req, &s)
+
+	return s, err
+}
+
+//GetBlockSteps retrieves a step block together with its steps
+func (t TaskServiceOp) GetBlockSteps(id, blockPath string) (Block, error) {
+	return t.GetBlockStepsContext(context.Background(), id, blockPath)
+}
+
+//GetBlockStepsContext is GetBlockSteps with a context that is attached to every request it makes
+func (t TaskServiceOp) GetBlockStepsContext(ctx context.Context, id, blockPath string) (Block, error) {
+	var b Block
+
+	url := taskBasePath + "/" + id + "/block/" + blockPath + "/step"
+
+	req, err := t.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return b, err
+	}
+
+	_, err = t.client.Do(req, &b)
+
+	return b, err
+}
+
+//IsFinal returns true when a task in this state will not change state anymore by itself
+func (s TaskState) IsFinal() bool {
+	switch s {
+	case TaskExecuted, TaskStopped, TaskFailed, TaskAborted, TaskCancelled, TaskDone:
+		return true
+	}
+	return false
+}
+
+//StepBlocks returns all step blocks in the block tree, depth first
+func (b Block) StepBlocks() []Block {
+	if len(b.Blocks) == 0 {
+		return []Block{b}
+	}
+
+	var sb []Block
+	for _, c := range b.Blocks {
+		sb = append(sb, c.StepBlocks()...)
+	}
+
+	return sb
+}
+
+//private functions
+
+func (t TaskServiceOp) action(ctx context.Context, id, a, verb string) error {
+
+	url := taskBasePath + "/" + id
+	if a != "" {
+		url = url + "/" + a
+	}
+
+	req, err := t.client.NewRequestContext(ctx, url, verb, nil)
+	if err != nil {
+		return err
+	}
+
+	// xldeploy answers these calls without a body
+	_, err = t.client.Do(req, ioutil.Discard)
+
+	return err
+}
+
+func (t TaskServiceOp) steps(ctx context.Context, id, a string, steps []string) (Task, error) {
+	var task Task
+
+	url := taskBasePath + "/" + id + "/" + a
+
+	req, err := t.client.NewRequestContext(ctx, url, "POST", steps)
+	if err != nil {
+		return task, err
+	}
+
+	_, err = t.client.Do(req, &task)
+
+	return task, err
+}
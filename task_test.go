@@ -0,0 +1,327 @@
+package xld
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetTask(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, mockTestTaskResponse)
+	})
+
+	task, err := client.Task.Get("testTask")
+	if err != nil {
+		t.Errorf("Task.Get returned error: %v", err)
+	}
+
+	if task.State != TaskExecuting {
+		t.Errorf("Expected state %v but got %v", TaskExecuting, task.State)
+	}
+
+	blocks := task.Block.StepBlocks()
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 step blocks but got %v", len(blocks))
+	}
+
+	if blocks[1].ID != "0_2" || blocks[1].State != TaskPending {
+		t.Errorf("Expected step block 0_2 to be PENDING but got %v %v", blocks[1].ID, blocks[1].State)
+	}
+}
+
+func TestTaskActions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := make(map[string]string)
+
+	mux.HandleFunc("/deployit/tasks/v2/", func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path] = r.Method
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		action func(string) error
+		path   string
+		method string
+	}{
+		{client.Task.Start, "/deployit/tasks/v2/testTask/start", "POST"},
+		{client.Task.Stop, "/deployit/tasks/v2/testTask/stop", "POST"},
+		{client.Task.Abort, "/deployit/tasks/v2/testTask/abort", "POST"},
+		{client.Task.Archive, "/deployit/tasks/v2/testTask/archive", "POST"},
+		{client.Task.Cancel, "/deployit/tasks/v2/testTask", "DELETE"},
+	}
+
+	for _, c := range cases {
+		err := c.action("testTask")
+		if err != nil {
+			t.Errorf("Task action %v returned error: %v", c.path, err)
+		}
+
+		if calls[c.path] != c.method {
+			t.Errorf("Expected %v %v but got %v", c.method, c.path, calls[c.path])
+		}
+	}
+}
+
+func TestSkipSteps(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/skip", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var steps []string
+		json.NewDecoder(r.Body).Decode(&steps)
+
+		if !reflect.DeepEqual(steps, []string{"0_1_1", "0_1_2"}) {
+			t.Errorf("Expected steps [0_1_1 0_1_2] but got %v", steps)
+		}
+
+		fmt.Fprint(w, mockTestTaskResponse)
+	})
+
+	_, err := client.Task.Skip("testTask", "0_1_1", "0_1_2")
+	if err != nil {
+		t.Errorf("Task.Skip returned error: %v", err)
+	}
+}
+
+func TestGetStep(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_1/step/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, mockTestStepResponse)
+	})
+
+	s, err := client.Task.GetStep("testTask", "0_1", "1")
+	if err != nil {
+		t.Errorf("Task.GetStep returned error: %v", err)
+	}
+
+	expected := Step{
+		Description:    "Copy testFile to testHost",
+		State:          StepFailed,
+		Log:            "Connection refused",
+		StartDate:      "2016-10-04T12:01:02.000+0200",
+		CompletionDate: "2016-10-04T12:01:03.000+0200",
+		FailureCount:   1,
+	}
+
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("Task.GetStep returned %+v, expected %+v", s, expected)
+	}
+}
+
+func TestWaitForTask(t *testing.T) {
+	setup()
+	defer teardown()
+
+	polls := 0
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+
+		polls++
+		if polls < 3 {
+			fmt.Fprint(w, mockTestTaskResponse)
+			return
+		}
+		fmt.Fprint(w, mockTestTaskExecutedResponse)
+	})
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_1/step", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id": "0_1", "state": "EXECUTING", "steps": [{"description": "Copy testFile to testHost", "state": "DONE"}]}`)
+	})
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_2/step", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id": "0_2", "state": "DONE", "steps": [{"description": "Verify testFile", "state": "DONE"}]}`)
+	})
+
+	var events []string
+
+	o := &WaitOptions{
+		Interval: time.Millisecond,
+		Progress: func(e TaskEvent) {
+			if e.Step == nil {
+				events = append(events, fmt.Sprintf("task %s", e.Task.State))
+				return
+			}
+			events = append(events, fmt.Sprintf("step %s/%s %s", e.BlockID, e.StepPath, e.Step.State))
+		},
+	}
+
+	task, err := client.Task.WaitForTask("testTask", o)
+	if err != nil {
+		t.Errorf("Task.WaitForTask returned error: %v", err)
+	}
+
+	if task.State != TaskExecuted {
+		t.Errorf("Expected state %v but got %v", TaskExecuted, task.State)
+	}
+
+	expected := []string{
+		"task EXECUTING",
+		"step 0_1/1 DONE",
+		"task EXECUTED",
+		"step 0_2/1 DONE",
+	}
+
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected events %v but got %v", expected, events)
+	}
+}
+
+func TestWaitForTaskFailed(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTaskFailedResponse)
+	})
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_1/step", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "0_1", "state": "FAILED", "steps": [{"description": "Copy testFile to testHost", "state": "FAILED"}]}`)
+	})
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_1/step/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestStepResponse)
+	})
+
+	_, err := client.Task.WaitForTask("testTask", &WaitOptions{Interval: time.Millisecond})
+
+	te, ok := err.(*TaskError)
+	if !ok {
+		t.Fatalf("Expected a *TaskError but got %v", err)
+	}
+
+	if te.State != TaskFailed || te.BlockID != "0_1" || te.StepPath != "1" {
+		t.Errorf("Expected step 0_1/1 to fail the task but got %+v", te)
+	}
+
+	if te.Step == nil || te.Step.Log != "Connection refused" {
+		t.Errorf("Expected the failed step log to be returned but got %+v", te.Step)
+	}
+}
+
+func TestWaitForTaskContext(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTaskResponse)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Task.WaitForTaskContext(ctx, "testTask", &WaitOptions{Interval: time.Millisecond})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected %v but got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWaitForTaskCanceled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// cancel while the steps of the failed block are fetched
+	mux.HandleFunc("/deployit/tasks/v2/testTask", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestTaskFailedResponse)
+	})
+
+	mux.HandleFunc("/deployit/tasks/v2/testTask/block/0_1/step", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	_, err := client.Task.WaitForTaskContext(ctx, "testTask", &WaitOptions{Interval: time.Millisecond})
+	if err != context.Canceled {
+		t.Errorf("Expected %v but got %v", context.Canceled, err)
+	}
+}
+
+var mockTestTaskResponse = `{
+  "id": "testTask",
+  "description": "Initial deployment of Environments/testEnv/testApp",
+  "state": "EXECUTING",
+  "owner": "admin",
+  "startDate": "2016-10-04T12:01:00.000+0200",
+  "failures": 0,
+  "activeBlocks": ["0_1"],
+  "metadata": {"environment": "testEnv", "application": "testApp", "version": "1.0"},
+  "block": {
+    "id": "0",
+    "description": "Deploy",
+    "state": "EXECUTING",
+    "blocks": [
+      {"id": "0_1", "description": "Deploy testFile", "state": "EXECUTING"},
+      {"id": "0_2", "description": "Verify testFile", "state": "PENDING"}
+    ]
+  }
+}`
+
+var mockTestStepResponse = `{
+  "description": "Copy testFile to testHost",
+  "state": "FAILED",
+  "log": "Connection refused",
+  "startDate": "2016-10-04T12:01:02.000+0200",
+  "completionDate": "2016-10-04T12:01:03.000+0200",
+  "failureCount": 1
+}`
+
+var mockTestTaskExecutedResponse = `{
+  "id": "testTask",
+  "description": "Initial deployment of Environments/testEnv/testApp",
+  "state": "EXECUTED",
+  "owner": "admin",
+  "startDate": "2016-10-04T12:01:00.000+0200",
+  "completionDate": "2016-10-04T12:01:10.000+0200",
+  "failures": 0,
+  "block": {
+    "id": "0",
+    "description": "Deploy",
+    "state": "EXECUTED",
+    "blocks": [
+      {"id": "0_1", "description": "Deploy testFile", "state": "DONE"},
+      {"id": "0_2", "description": "Verify testFile", "state": "DONE"}
+    ]
+  }
+}`
+
+var mockTestTaskFailedResponse = `{
+  "id": "testTask",
+  "description": "Initial deployment of Environments/testEnv/testApp",
+  "state": "FAILED",
+  "owner": "admin",
+  "startDate": "2016-10-04T12:01:00.000+0200",
+  "failures": 1,
+  "block": {
+    "id": "0",
+    "description": "Deploy",
+    "state": "FAILED",
+    "blocks": [
+      {"id": "0_1", "description": "Deploy testFile", "state": "FAILED"},
+      {"id": "0_2", "description": "Verify testFile", "state": "PENDING"}
+    ]
+  }
+}`
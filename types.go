@@ -0,0 +1,206 @@
+package xld
+
+import (
+	"context"
+	"sort"
+)
+
+//ListTypes retrieves the descriptors of every type known to xldeploy
+// the descriptors are added to the metadata cache
+func (m MetaDataServiceOp) ListTypes() (MetaDataList, error) {
+	return m.ListTypesContext(context.Background())
+}
+
+//ListTypesContext is ListTypes with a context that is attached to every request it makes
+func (m MetaDataServiceOp) ListTypesContext(ctx context.Context) (MetaDataList, error) {
+
+	var metaList MetaDataList
+
+	url := MetaDataBasePath + "/" + "type"
+
+	req, err := m.client.NewRequestContext(ctx, url, "GET", nil)
+	if err != nil {
+		return metaList, err
+	}
+
+	_, err = m.client.Do(req, &metaList)
+	if err != nil {
+		return metaList, err
+	}
+
+	m.cache.putAll(metaList)
+
+	return metaList, nil
+}
+
+//ListDescendants returns every type that extends or implements superType
+// superType itself is not part of the result, the type system is read from the cache when it was preloaded
+func (m MetaDataServiceOp) ListDescendants(superType string) (MetaDataList, error) {
+	return m.ListDescendantsContext(context.Background(), superType)
+}
+
+//ListDescendantsContext is ListDescendants with a context that is attached to every request it makes
+func (m MetaDataServiceOp) ListDescendantsContext(ctx context.Context, superType string) (MetaDataList, error) {
+	var d MetaDataList
+
+	types, err := m.typeList(ctx)
+	if err != nil {
+		return d, err
+	}
+
+	ts := newTypeSystem(types)
+
+	for _, t := range types {
+		if t.Type != superType && ts.isSubtypeOf(t.Type, superType) {
+			d = append(d, t)
+		}
+	}
+
+	return d, nil
+}
+
+//IsSubtypeOf reports whether t is superType or extends or implements it, directly or through its super types
+// the type system is read from the cache when it was preloaded, otherwise the types are fetched one by one
+func (m MetaDataServiceOp) IsSubtypeOf(t, superType string) (bool, error) {
+	return m.IsSubtypeOfContext(context.Background(), t, superType)
+}
+
+//IsSubtypeOfContext is IsSubtypeOf with a context that is attached to every request it makes
+func (m MetaDataServiceOp) IsSubtypeOfContext(ctx context.Context, t, superType string) (bool, error) {
+	if t == superType {
+		return true, nil
+	}
+
+	if types, ok := m.cache.all(); ok {
+		ts := newTypeSystem(types)
+		if _, ok := ts[t]; ok {
+			return ts.isSubtypeOf(t, superType), nil
+		}
+	}
+
+	visited := make(map[string]bool)
+	todo := []string{t}
+
+	for len(todo) > 0 {
+		n := todo[0]
+		todo = todo[1:]
+
+		if visited[n] {
+			continue
+		}
+		visited[n] = true
+
+		d, err := m.GetTypeContext(ctx, n)
+		if err != nil {
+			return false, err
+		}
+
+		todo = append(todo, d.SuperTypes...)
+		todo = append(todo, d.Interfaces...)
+
+		for _, s := range todo {
+			if s == superType {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+//DeployableTypesFor returns the deployable types that can be deployed to a container of containerType
+// the result is sorted and holds every type only once, the type system is read from the cache when it was preloaded
+func (m MetaDataServiceOp) DeployableTypesFor(containerType string) ([]string, error) {
+	return m.DeployableTypesForContext(context.Background(), containerType)
+}
+
+//DeployableTypesForContext is DeployableTypesFor with a context that is attached to every request it makes
+func (m MetaDataServiceOp) DeployableTypesForContext(ctx context.Context, containerType string) ([]string, error) {
+	var d []string
+
+	types, err := m.typeList(ctx)
+	if err != nil {
+		return d, err
+	}
+
+	ts := newTypeSystem(types)
+	seen := make(map[string]bool)
+
+	// a deployed type links its deployable type to the container type it can be deployed to
+	for _, t := range types {
+		if t.Virtual || t.DeployableType == "" || t.ContainerType == "" || seen[t.DeployableType] {
+			continue
+		}
+
+		if ts.isSubtypeOf(containerType, t.ContainerType) {
+			seen[t.DeployableType] = true
+			d = append(d, t.DeployableType)
+		}
+	}
+
+	sort.Strings(d)
+
+	return d, nil
+}
+
+//ControlTasksFor returns the control tasks that can be run on a ci of type t
+func (m MetaDataServiceOp) ControlTasksFor(t string) ([]ControlTask, error) {
+	return m.ControlTasksForContext(context.Background(), t)
+}
+
+//ControlTasksForContext is ControlTasksFor with a context that is attached to every request it makes
+func (m MetaDataServiceOp) ControlTasksForContext(ctx context.Context, t string) ([]ControlTask, error) {
+	d, err := m.GetTypeContext(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.ControlTasks, nil
+}
+
+//private functions
+
+//typeList returns the complete type system from the cache, it is only fetched when the cache is cold
+func (m MetaDataServiceOp) typeList(ctx context.Context) (MetaDataList, error) {
+	if types, ok := m.cache.all(); ok {
+		return types, nil
+	}
+
+	return m.ListTypesContext(ctx)
+}
+
+//typeSystem answers hierarchy questions from a complete list of types without further requests
+type typeSystem map[string]MetaData
+
+func newTypeSystem(types MetaDataList) typeSystem {
+	ts := make(typeSystem, len(types))
+	for _, t := range types {
+		ts[t.Type] = t
+	}
+	return ts
+}
+
+func (ts typeSystem) isSubtypeOf(t, superType string) bool {
+	visited := make(map[string]bool)
+	todo := []string{t}
+
+	for len(todo) > 0 {
+		n := todo[0]
+		todo = todo[1:]
+
+		if n == superType {
+			return true
+		}
+
+		if visited[n] {
+			continue
+		}
+		visited[n] = true
+
+		d := ts[n]
+		todo = append(todo, d.SuperTypes...)
+		todo = append(todo, d.Interfaces...)
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package xld
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+//ValidationLevelError is the level of the validation messages ValidateCi reports
+const ValidationLevelError = "ERROR"
+
+//ValidateCi checks a ci against the metadata of its type without sending it to xldeploy
+// every violation is reported in a single *ValidationError:
+// unknown properties, missing required properties, values that do not fit the property kind,
+// enum values that are not allowed and references to cis of the wrong type.
+// only rules xldeploy enforces itself are checked, a ci xldeploy accepts is never refused here
+func (r RepositoryServiceOp) ValidateCi(c Ci) error {
+	return r.ValidateCiContext(context.Background(), c)
+}
+
+//ValidateCiContext is ValidateCi with a context that is attached to every request it makes
+func (r RepositoryServiceOp) ValidateCiContext(ctx context.Context, c Ci) error {
+	if _, err := validateID(c.ID); err != nil {
+		return err
+	}
+
+	meta, err := r.client.Meta.GetTypeContext(ctx, c.Type)
+	if err != nil {
+		return err
+	}
+
+	e := &ValidationError{CiID: c.ID}
+	invalid := func(p, m string) {
+		e.Messages = append(e.Messages, ValidationMessage{CiID: c.ID, Property: p, Level: ValidationLevelError, Message: m})
+	}
+
+	known := make(map[string]bool, len(meta.Properties))
+
+	for _, p := range meta.Properties {
+		known[p.Name] = true
+
+		v := c.Properties[p.Name]
+		if v == nil {
+			if p.Required && p.Default == nil && !p.AsContainment {
+				invalid(p.Name, "property is required")
+			}
+			continue
+		}
+
+		cv, err := convertProperty(p.Kind, v)
+		if err != nil {
+			invalid(p.Name, err.Error())
+			continue
+		}
+
+		if isEmptyProperty(cv) {
+			if p.Required && p.Default == nil && !p.AsContainment {
+				invalid(p.Name, "property is required")
+			}
+			continue
+		}
+
+		switch p.Kind {
+		case KindEnum:
+			if len(p.EnumValues) > 0 && !containsString(p.EnumValues, cv.(string)) {
+				invalid(p.Name, "value "+cv.(string)+" is not one of "+strings.Join(p.EnumValues, ", "))
+			}
+		case KindCi, KindSetOfCi, KindListOfCi:
+			if p.ReferencedType == "" {
+				continue
+			}
+			m, err := r.checkReferences(ctx, v, p.ReferencedType)
+			if err != nil {
+				return err
+			}
+			if m != "" {
+				invalid(p.Name, m)
+			}
+		}
+	}
+
+	var unknown []string
+	for k := range c.Properties {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		invalid(k, "unknown property for type "+c.Type)
+	}
+
+	if len(e.Messages) > 0 {
+		return e
+	}
+
+	return nil
+}
+
+//private functions
+
+//checkReferences verifies the type of the referenced cis that carry their type
+// references that are plain ids are not looked up
+func (r RepositoryServiceOp) checkReferences(ctx context.Context, v interface{}, referencedType string) (string, error) {
+	var refs []interface{}
+
+	switch v := v.(type) {
+	case []interface{}:
+		refs = v
+	case []Ci:
+		for _, c := range v {
+			refs = append(refs, c)
+		}
+	case CiList:
+		for _, c := range v {
+			refs = append(refs, c)
+		}
+	case []CiListEntry:
+		for _, c := range v {
+			refs = append(refs, c)
+		}
+	default:
+		refs = []interface{}{v}
+	}
+
+	for _, ref := range refs {
+		var id, t string
+
+		switch ref := ref.(type) {
+		case Ci:
+			id, t = ref.ID, ref.Type
+		case *Ci:
+			id, t = ref.ID, ref.Type
+		case CiListEntry:
+			id, t = ref.ID, ref.Type
+		case map[string]interface{}:
+			id, _ = ref["id"].(string)
+			t, _ = ref["type"].(string)
+		case map[string]string:
+			id, t = ref["id"], ref["type"]
+		}
+
+		if t == "" {
+			continue
+		}
+
+		ok, err := r.client.Meta.IsSubtypeOfContext(ctx, t, referencedType)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return id + " is a " + t + ", expected a " + referencedType, nil
+		}
+	}
+
+	return "", nil
+}
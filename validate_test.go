@@ -0,0 +1,68 @@
+package xld
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCi(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+
+	err := client.Repository.ValidateCi(Ci{ID: "Infrastructure/testHost", Type: "overthere.SshHost", Properties: map[string]interface{}{
+		"os":       "BEOS",
+		"port":     "twenty-two",
+		"username": "root\nadmin",
+		"bogus":    "value",
+	}})
+
+	e, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a *ValidationError but got %v", err)
+	}
+
+	var properties []string
+	for _, m := range e.Messages {
+		properties = append(properties, m.Property)
+	}
+
+	// size is a display hint, multi-line values are accepted for every string
+	expected := []string{"os", "address", "port", "bogus"}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Errorf("Expected violations for %v but got %v", expected, e)
+	}
+
+	err = client.Repository.ValidateCi(Ci{ID: "Infrastructure/testHost", Type: "overthere.SshHost", Properties: map[string]interface{}{
+		"os":      "UNIX",
+		"address": "localhost",
+	}})
+	if err != nil {
+		t.Errorf("Expected a valid ci but got %v", err)
+	}
+}
+
+func TestValidateBeforeSave(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Config.ValidateBeforeSave = true
+	defer func() { client.Config.ValidateBeforeSave = false }()
+
+	mux.HandleFunc("/deployit/metadata/type/overthere.SshHost", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mockTestSshHostMetaResponse)
+	})
+	mux.HandleFunc("/deployit/repository/ci/Infrastructure/testHost", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the invalid ci not to be sent but got a %v", r.Method)
+	})
+
+	_, err := client.Repository.CreateCi("Infrastructure/testHost", "overthere.SshHost", map[string]interface{}{"os": "BEOS"})
+	if !IsValidationError(err) {
+		t.Errorf("Expected a validation error but got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package xld
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWaitInterval    = time.Second
+	defaultWaitMaxInterval = 15 * time.Second
+	defaultWaitBackoff     = 1.5
+)
+
+//WaitOptions configures how WaitForTask polls a task
+type WaitOptions struct {
+	//Interval is the time between the first polls, defaults to 1 second
+	Interval time.Duration
+	//MaxInterval caps the time between two polls, defaults to 15 seconds
+	MaxInterval time.Duration
+	//Backoff multiplies the interval after every poll that brought no change, defaults to 1.5
+	Backoff float64
+	//Progress is called on every task and step state transition
+	Progress func(TaskEvent)
+}
+
+//TaskEvent describes a state transition of a task or one of its steps
+// for task level events Step is nil
+type TaskEvent struct {
+	Task     Task
+	BlockID  string
+	StepPath string
+	Step     *Step
+	Previous string
+}
+
+//TaskError is returned by WaitForTask when a task ends in any other state than EXECUTED or DONE
+// when a step failed it holds that step, including its log
+type TaskError struct {
+	TaskID   string
+	State    TaskState
+	BlockID  string
+	StepPath string
+	Step     *Step
+}
+
+func (e *TaskError) Error() string {
+	if e.Step == nil {
+		return fmt.Sprintf("task %s ended in state %s", e.TaskID, e.State)
+	}
+
+	return fmt.Sprintf("task %s ended in state %s: step %s/%s (%s) failed:\n%s", e.TaskID, e.State, e.BlockID, e.StepPath, e.Step.Description, e.Step.Log)
+}
+
+//StartAndWait starts a task and blocks until it is finished, see WaitForTask
+func (t TaskServiceOp) StartAndWait(id string, o *WaitOptions) (Task, error) {
+	return t.StartAndWaitContext(context.Background(), id, o)
+}
+
+//StartAndWaitContext is StartAndWait with a context that is attached to every request it makes
+// the wait ends when the context is done
+func (t TaskServiceOp) StartAndWaitContext(ctx context.Context, id string, o *WaitOptions) (Task, error) {
+	if err := t.StartContext(ctx, id); err != nil {
+		return Task{}, err
+	}
+
+	return t.WaitForTaskContext(ctx, id, o)
+}
+
+//WaitForTask polls a task until it reaches a final state
+// a task that ends in EXECUTED or DONE is returned without error,
+// any other final state results in a *TaskError
+func (t TaskServiceOp) WaitForTask(id string, o *WaitOptions) (Task, error) {
+	return t.WaitForTaskContext(context.Background(), id, o)
+}
+
+//WaitForTaskContext is WaitForTask with a context that is attached to every request it makes
+// the wait ends with the context error when the context is done
+func (t TaskServiceOp) WaitForTaskContext(ctx context.Context, id string, o *WaitOptions) (Task, error) {
+	w := newTaskWaiter(t, id, o)
+
+	interval := w.opts.Interval
+
+	for {
+		task, changed, err := w.poll(ctx)
+		if err != nil {
+			return task, contextError(ctx, err)
+		}
+
+		if task.State.IsFinal() {
+			return task, w.result(ctx, task)
+		}
+
+		if changed {
+			interval = w.opts.Interval
+		} else {
+			interval = time.Duration(float64(interval) * w.opts.Backoff)
+			if interval > w.opts.MaxInterval {
+				interval = w.opts.MaxInterval
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+//private functions
+
+type taskWaiter struct {
+	tasks  TaskServiceOp
+	id     string
+	opts   WaitOptions
+	state  TaskState
+	blocks map[string]TaskState
+	steps  map[string]StepState
+}
+
+func newTaskWaiter(t TaskServiceOp, id string, o *WaitOptions) *taskWaiter {
+	w := &taskWaiter{
+		tasks:  t,
+		id:     id,
+		blocks: make(map[string]TaskState),
+		steps:  make(map[string]StepState),
+	}
+
+	if o != nil {
+		w.opts = *o
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = defaultWaitInterval
+	}
+	if w.opts.MaxInterval < w.opts.Interval {
+		w.opts.MaxInterval = defaultWaitMaxInterval
+		if w.opts.MaxInterval < w.opts.Interval {
+			w.opts.MaxInterval = w.opts.Interval
+		}
+	}
+	if w.opts.Backoff < 1 {
+		w.opts.Backoff = defaultWaitBackoff
+	}
+
+	return w
+}
+
+//poll fetches the task, reports transitions and tells if anything changed since the last poll
+func (w *taskWaiter) poll(ctx context.Context) (Task, bool, error) {
+	task, err := w.tasks.GetContext(ctx, w.id)
+	if err != nil {
+		return task, false, err
+	}
+
+	changed := false
+
+	if task.State != w.state {
+		w.emit(TaskEvent{Task: task, Previous: string(w.state)})
+		w.state = task.State
+		changed = true
+	}
+
+	// fetch the steps of blocks that changed state and of the active blocks,
+	// the latter can have step transitions without the block changing state
+	var fetch []string
+
+	for _, b := range task.Block.StepBlocks() {
+		if b.State == w.blocks[b.ID] {
+			continue
+		}
+		w.blocks[b.ID] = b.State
+		changed = true
+
+		if b.State != TaskPending {
+			fetch = append(fetch, b.ID)
+		}
+	}
+
+	for _, a := range task.ActiveBlocks {
+		if !containsString(fetch, a) {
+			fetch = append(fetch, a)
+		}
+	}
+
+	if w.opts.Progress == nil {
+		return task, changed, nil
+	}
+
+	for _, id := range fetch {
+		sb, err := w.tasks.GetBlockStepsContext(ctx, w.id, id)
+		if err != nil {
+			return task, changed, err
+		}
+		if w.stepEvents(task, id, sb) {
+			changed = true
+		}
+	}
+
+	return task, changed, nil
+}
+
+func (w *taskWaiter) stepEvents(task Task, blockID string, b Block) bool {
+	changed := false
+
+	for i := range b.Steps {
+		s := b.Steps[i]
+		p := strconv.Itoa(i + 1)
+		k := blockID + "/" + p
+
+		prev := w.steps[k]
+		if s.State == prev {
+			continue
+		}
+		w.steps[k] = s.State
+		changed = true
+
+		w.emit(TaskEvent{Task: task, BlockID: blockID, StepPath: p, Step: &s, Previous: string(prev)})
+	}
+
+	return changed
+}
+
+func (w *taskWaiter) emit(e TaskEvent) {
+	if w.opts.Progress != nil {
+		w.opts.Progress(e)
+	}
+}
+
+//result turns a finished task into the error WaitForTask should return
+func (w *taskWaiter) result(ctx context.Context, task Task) error {
+	if task.State == TaskExecuted || task.State == TaskDone {
+		return nil
+	}
+
+	te := &TaskError{TaskID: task.ID, State: task.State}
+
+	for _, b := range task.Block.StepBlocks() {
+		if b.State != TaskFailed && b.State != TaskStopped {
+			continue
+		}
+
+		sb, err := w.tasks.GetBlockStepsContext(ctx, w.id, b.ID)
+		if err != nil {
+			return contextError(ctx, err)
+		}
+
+		for i, s := range sb.Steps {
+			if s.State != StepFailed {
+				continue
+			}
+
+			p := strconv.Itoa(i + 1)
+
+			// the step listing does not always carry the full log
+			fs, err := w.tasks.GetStepContext(ctx, w.id, b.ID, p)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				s = fs
+			}
+
+			te.BlockID = b.ID
+			te.StepPath = p
+			te.Step = &s
+
+			return te
+		}
+	}
+
+	return te
+}
+
+//contextError reports a request that was aborted by the context with the context error, like the wait does
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package xld
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+//private functions
+
+//decodeJSONOrYAML decodes json or yaml from r into v, content that starts with { or [ is json
+// yaml is converted to json first so both formats share the layout of the json tags and custom decoders
+func decodeJSONOrYAML(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if t := bytes.TrimSpace(b); len(t) > 0 && (t[0] == '{' || t[0] == '[') {
+		return json.Unmarshal(b, v)
+	}
+
+	var y interface{}
+	if err := yaml.Unmarshal(b, &y); err != nil {
+		return err
+	}
+
+	j, err := json.Marshal(jsonValue(y))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(j, v)
+}
+
+//encodeYAML writes v as yaml in the layout json.Marshal gives it, objects keep the order of their json keys
+func encodeYAML(w io.Writer, v interface{}) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// json is valid yaml, decoding into a MapSlice keeps the key order
+	var y interface{}
+	if len(j) > 0 && j[0] == '{' {
+		var m yaml.MapSlice
+		err = yaml.Unmarshal(j, &m)
+		y = m
+	} else {
+		err = yaml.Unmarshal(j, &y)
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(y)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+//jsonValue turns the maps the yaml decoder returns into maps json can encode
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = jsonValue(e)
+		}
+		return l
+	}
+
+	return v
+}